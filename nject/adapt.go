@@ -0,0 +1,123 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NilPointerError is the TerminalError when a provider annotated with
+// AdaptPointers provides a nil pointer to a consumer that takes the value
+// that it points to.
+type NilPointerError struct {
+	// Type is the pointer type
+	Type reflect.Type
+}
+
+func (e NilPointerError) Error() string {
+	return fmt.Sprintf("cannot dereference nil %s", e.Type)
+}
+
+// AdaptPointers annotates a provider to indicate that its outputs and
+// return values can be used by consumers that differ by one pointer level:
+// a *Config can be given to a consumer of Config and a Config can be given
+// to a consumer of *Config.  Like ConvertibleTo, this is only done when no
+// provider has the exact type.
+//
+// When a consumer of Config is given a nil *Config, the chain stops and a
+// NilPointerError is returned as if it were a TerminalError: wrappers
+// above the consumer see it as the error returned by their inner function.
+// If nothing returns error, the invoke function panics instead.
+// In the STATIC chain, the NilPointerError is returned as an
+// initialization error.
+//
+// When a consumer of *Config is given a Config, it gets a pointer to
+// a copy of the Config so that it cannot modify the value that other
+// providers see.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func AdaptPointers(fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.adaptPointers = true
+	})
+}
+
+// differByPointer is true if one of the types is a pointer to the other
+func differByPointer(a, b reflect.Type) bool {
+	return (a.Kind() == reflect.Ptr && a.Elem() == b) || (b.Kind() == reflect.Ptr && b.Elem() == a)
+}
+
+// adaptPanic carries a NilPointerError from an input mapper to
+// the code that runs the chain
+type adaptPanic struct {
+	err error
+}
+
+// converter returns a function that adapts values of type from to type to
+// for ConvertibleTo, AutoConvert, and AdaptPointers
+func converter(from, to reflect.Type) func(reflect.Value) reflect.Value {
+	switch {
+	case from.Kind() == reflect.Ptr && from.Elem() == to:
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				panic(adaptPanic{err: NilPointerError{Type: from}})
+			}
+			return v.Elem()
+		}
+	case to.Kind() == reflect.Ptr && to.Elem() == from:
+		return func(v reflect.Value) reflect.Value {
+			p := reflect.New(from)
+			p.Elem().Set(v)
+			return p
+		}
+	default:
+		return func(v reflect.Value) reflect.Value {
+			return v.Convert(to)
+		}
+	}
+}
+
+// catchNilPointer makes a NilPointerError from an AdaptPointers provider
+// below a wrapper look like a TerminalError returned to that wrapper.
+// It returns nil if there is nowhere to put the error.
+func catchNilPointer(zero func() valueCollection, upVmap map[typeCode]int) func(next func(valueCollection) valueCollection, v valueCollection) valueCollection {
+	errorIndex, found := upVmap[getTypeCode(errorType)]
+	if !found || errorIndex < 0 {
+		return nil
+	}
+	return func(next func(valueCollection) valueCollection, v valueCollection) (upV valueCollection) {
+		defer func() {
+			if r := recover(); r != nil {
+				ap, ok := r.(adaptPanic)
+				if !ok {
+					panic(r)
+				}
+				upV = zero()
+				upV[errorIndex] = reflect.ValueOf(&ap.err).Elem()
+			}
+		}()
+		return next(v)
+	}
+}
+
+// adaptsPointers is true if any included provider is annotated with
+// AdaptPointers.  Only then can the chain panic with a NilPointerError.
+func adaptsPointers(funcs []*provider) bool {
+	for _, fm := range funcs {
+		if fm.include && fm.adaptPointers {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverNilPointer turns a NilPointerError panic into an error.  It
+// must be deferred.
+func recoverNilPointer(err *error) {
+	if r := recover(); r != nil {
+		ap, ok := r.(adaptPanic)
+		if !ok {
+			panic(r)
+		}
+		*err = ap.err
+	}
+}
@@ -0,0 +1,100 @@
+package nject
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type adaptConfig struct {
+	Name string
+}
+
+func TestAdaptPointersDereference(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got adaptConfig
+		err := Run("dereference",
+			AdaptPointers(func() *adaptConfig { return &adaptConfig{Name: "ptr"} }),
+			func(c adaptConfig) { got = c },
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "ptr", got.Name)
+	})
+}
+
+func TestAdaptPointersAddressCopies(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() string
+		require.NoError(t, Sequence("address",
+			AdaptPointers(adaptConfig{Name: "static"}),
+			func(c *adaptConfig) s1 {
+				c.Name = "modified"
+				return s1(c.Name)
+			},
+			func(a s1, c adaptConfig) string { return string(a) + " " + c.Name },
+		).Bind(&invoke, nil))
+		assert.Equal(t, "modified static", invoke())
+		assert.Equal(t, "modified static", invoke())
+	})
+}
+
+func TestAdaptPointersRequiresAnnotation(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		err := Run("not annotated",
+			func() *adaptConfig { return &adaptConfig{} },
+			func(c adaptConfig) {},
+		)
+		assert.Error(t, err)
+	})
+}
+
+func TestAdaptPointersNil(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var wrapperSaw error
+		var invoke func() error
+		require.NoError(t, Sequence("nil",
+			func(inner func() error) error {
+				wrapperSaw = inner()
+				return wrapperSaw
+			},
+			AdaptPointers(func() *adaptConfig { return nil }),
+			func(c adaptConfig) error {
+				t.Error("should not be called")
+				return nil
+			},
+		).Bind(&invoke, nil))
+		err := invoke()
+		var npe NilPointerError
+		if assert.True(t, errors.As(err, &npe), "error %v", err) {
+			assert.Equal(t, reflect.TypeOf(&adaptConfig{}), npe.Type)
+		}
+		assert.Equal(t, err, wrapperSaw)
+	})
+}
+
+func TestAdaptPointersNilWithoutError(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func()
+		require.NoError(t, Sequence("nil",
+			AdaptPointers(func() *adaptConfig { return nil }),
+			func(c adaptConfig) {},
+		).Bind(&invoke, nil))
+		assert.Panics(t, invoke)
+	})
+}
+
+func TestAdaptPointersNilStatic(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() error
+		require.NoError(t, Sequence("nil static",
+			AdaptPointers((*adaptConfig)(nil)),
+			func(c adaptConfig) s1 { return s1(c.Name) },
+			func(s s1) error { return nil },
+		).Bind(&invoke, nil))
+		var npe NilPointerError
+		assert.True(t, errors.As(invoke(), &npe))
+	})
+}
@@ -4,6 +4,7 @@ package nject
 
 import (
 	"fmt"
+	"io"
 	"reflect"
 )
 
@@ -205,6 +206,102 @@ func callsInner(fn interface{}) Provider {
 	})
 }
 
+// OptionalInputs creates a new provider and annotates it as
+// able to run without some of its inputs.  Normally a provider whose
+// inputs cannot all be found is excluded from the chain.  With
+// OptionalInputs, inputs that have no provider are given their
+// zero value instead.  To find out which inputs are present, the
+// provider can also take a Presence as an input.
+//
+// OptionalInputs only applies to inputs, not to the values returned
+// from inner() by wrappers.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func OptionalInputs(fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.optionalInputs = true
+	})
+}
+
+// Default creates a new provider and annotates it as a fallback:
+// the types it provides are only taken from it if no other
+// provider in the chain provides them.  Unlike the normal rule,
+// where the closest provider of a type wins, position does not
+// matter: a Default provider loses to other providers that are
+// before it or after it.
+//
+// Default is meant for libraries that ship collections with
+// sane defaults (a no-op logger, a real clock) that applications
+// can override by including their own provider anywhere.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func Default(fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.isDefault = true
+	})
+}
+
+// DefaultFor is like Default except that it only applies to the listed
+// types.  The provider's other outputs follow the normal rules: they are
+// taken from the closest provider.  Use it on a Collection to let providers
+// elsewhere in the chain replace the collection's providers of some types
+// without changing the rest.  Types that the provider does not provide are
+// ignored.  DefaultFor can be applied more than once to add more types.
+//
+// Types that are private to a Module are listed by their Go types.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func DefaultFor(types []reflect.Type, fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		defaultTypes := make(map[typeCode]bool, len(fm.defaultTypes)+len(types))
+		for tc := range fm.defaultTypes {
+			defaultTypes[tc] = true
+		}
+		for _, t := range types {
+			defaultTypes[getTypeCode(t)] = true
+		}
+		fm.defaultTypes = defaultTypes
+	})
+}
+
+// ConvertibleTo annotates a provider to indicate that its outputs and
+// return values can be converted to the listed types.  When nothing
+// in the chain provides one of those types, a consumer of that type can
+// get it by converting a value from this provider.  For example, with
+//
+//	type UserID string
+//	type AccountOwnerID string
+//
+// a provider of UserID that is annotated with
+// ConvertibleTo([]reflect.Type{reflect.TypeOf(AccountOwnerID(""))}, ...)
+// can satisfy inputs of AccountOwnerID.  The conversion must be one that
+// Go allows.  An exact match is always preferred over a conversion.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func ConvertibleTo(types []reflect.Type, fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		convertTo := make(map[typeCode]bool, len(fm.convertTo)+len(types))
+		for tc := range fm.convertTo {
+			convertTo[tc] = true
+		}
+		for _, t := range types {
+			convertTo[getTypeCode(t)] = true
+		}
+		fm.convertTo = convertTo
+	})
+}
+
+// AutoConvert is like ConvertibleTo except that the outputs and return
+// values of the provider can be converted to any type that has the same
+// underlying type, eg from UserID to AccountOwnerID when both are strings.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func AutoConvert(fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.autoConvert = true
+	})
+}
+
 // Loose annotates a wrap function to indicate that when trying
 // to match types against the outputs and return values from this
 // provider, an in-exact match is acceptable.  This matters when inputs and
@@ -213,6 +310,19 @@ func callsInner(fn interface{}) Provider {
 // return values of this provider if the output/return value
 // implements the interface.
 //
+// Loose also applies in the other direction for the values returned to
+// a wrapper by its inner function: a Loose wrapper whose inner function
+// returns an interface can be matched to the return values of providers
+// below it that implement the interface even if they are not annotated.
+//
+// When more than one type could be matched to an interface, the closest
+// provider wins.  If that does not decide it, a type from the same
+// package as the interface wins, then the type with the most methods,
+// and then the type whose fully-qualified name sorts first.  For the
+// values returned to a Loose wrapper, there is no tie-break by name:
+// the match is ambiguous and the wrapper cannot be included.  See
+// AmbiguousMatchError.
+//
 // By default, an exact match of types is required for all providers.
 func Loose(fn interface{}) Provider {
 	return newThing(fn).modify(func(fm *provider) {
@@ -220,6 +330,75 @@ func Loose(fn interface{}) Provider {
 	})
 }
 
+// Overrides annotates a provider as intentionally replacing earlier
+// providers of the same types.  This only matters when binding with
+// Strict or StrictWarnings: the shadowing is not reported and the
+// providers that are shadowed are not reported as unused.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func Overrides(fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.overrides = true
+	})
+}
+
+// BindOption modifies the behavior of Bind.  BindOptions can be
+// passed to Bind, MustBind, and Collection.SetCallback.
+type BindOption func(*bindOptions)
+
+type bindOptions struct {
+	debug          *debugSink
+	strict         bool
+	strictWarnings io.Writer
+	asyncWorkers   int
+	retryableInit  bool
+	reload         *func() error
+}
+
+func newBindOptions(opts []BindOption) bindOptions {
+	var o bindOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.debug == nil {
+		o.debug = globalDebugSink()
+	}
+	return o
+}
+
+// DebugTo is a BindOption that writes a detailed trace of how Bind
+// decided which providers to include to w.  Calls to the bound
+// functions are also traced to w.  The trace is the same as the one
+// included in DetailedError.
+//
+// Each Bind that uses DebugTo gets its own trace so concurrent binds
+// do not interfere with each other.  Tracing is slow: DebugTo is meant
+// for diagnosing problems, not for production use.
+func DebugTo(w io.Writer) BindOption {
+	return func(o *bindOptions) {
+		o.debug = newDebugSink(w)
+	}
+}
+
+// RetryableInit is a BindOption that allows initialization to be tried
+// again after the STATIC chain fails.  Without it, initialization only
+// happens once and a failure is permanent.
+//
+// When the STATIC chain fails and nothing consumes its error (there is no
+// init function or the init function does not return error, and nothing in
+// the RUN chain takes error as an input), the invoke function returns the
+// error (or panics if the invoke function does not return error).
+//
+// With RetryableInit, calling the init function (or the invoke function
+// if there is no init function) after a failure runs the STATIC chain again.
+// Each attempt starts from scratch.  Calls to the invoke function made while
+// a retry is running see the values from the previous attempt.
+func RetryableInit() BindOption {
+	return func(o *bindOptions) {
+		o.retryableInit = true
+	}
+}
+
 // Bind expects to receive two function pointers for functions
 // that are not yet defined.  Bind defines the functions.  The
 // first function is called to invoke the Collection of providers.
@@ -241,33 +420,85 @@ func Loose(fn interface{}) Provider {
 //
 // Bind pre-computes as much as possible so that the invokeFunc is
 // fast.
-func (c *Collection) Bind(invokeFunc interface{}, initFunc interface{}) error {
-	if err := c.bindFast(invokeFunc, initFunc); err != nil {
-		invokeF := newProvider(invokeFunc, -1, c.name+" invoke func")
-		var initF *provider
-		if initFunc != nil {
-			initF = newProvider(initFunc, -1, c.name+" initialization func")
-		}
-
-		debugOutput := captureDoBindDebugging(c, invokeF, initF)
-		return &njectError{
-			err:     err,
-			details: debugOutput,
-		}
+//
+// When Bind fails, the error returned includes a debugging trace
+// that can be retrieved with DetailedError().  Capturing the trace
+// does not block other calls to Bind.
+func (c *Collection) Bind(invokeFunc interface{}, initFunc interface{}, opts ...BindOption) error {
+	options := newBindOptions(opts)
+	if options.asyncWorkers != 0 {
+		return errAsyncWorkers
+	}
+	if err := c.bindFast(invokeFunc, initFunc, options); err != nil {
+		return c.detailedBindError(err, invokeFunc, initFunc, options)
 	}
 	return nil
 }
 
-func (c *Collection) bindFast(invokeFunc interface{}, initFunc interface{}) error {
+func (c *Collection) detailedBindError(err error, invokeFunc interface{}, initFunc interface{}, options bindOptions) error {
+	invokeF := newProvider(invokeFunc, -1, c.name+" invoke func")
+	var initF *provider
+	if initFunc != nil {
+		initF = newProvider(initFunc, -1, c.name+" initialization func")
+	}
+
+	debugOutput := captureDoBindDebugging(c, invokeF, initF, options)
+	return &njectError{
+		err:     err,
+		details: debugOutput,
+		reproduceFile: func(pkgName string) string {
+			return generateReproduceFile(pkgName, characterizeForReproduce(c), invokeF, initF, false)
+		},
+	}
+}
+
+func (c *Collection) bindFast(invokeFunc interface{}, initFunc interface{}, options bindOptions) error {
 	invokeF := newProvider(invokeFunc, -1, c.name+" invoke func")
 	var initF *provider
 	if initFunc != nil {
 		initF = newProvider(initFunc, -1, c.name+" initialization func")
 	}
 
-	debugLock.RLock()
-	defer debugLock.RUnlock()
-	return doBind(c, invokeF, initF, true)
+	return doBind(c, invokeF, initF, bindReal, options)
+}
+
+// Validate checks that the Collection could be bound to an invoke function
+// of type invokeType and an init function of type initType.  initType may be
+// nil.  Validate does the same dependency analysis that Bind does but it does
+// not generate or bind any functions so it is cheaper than Bind.  It never calls
+// any providers.  It is meant for table-driven tests and self-checks at program
+// startup.
+//
+// There is no generic form of Validate because this module still supports
+// versions of Go that do not have generics.
+//
+// Like Bind, errors returned by Validate can be passed to DetailedError().
+func (c *Collection) Validate(invokeType reflect.Type, initType reflect.Type, opts ...BindOption) error {
+	if invokeType == nil || invokeType.Kind() != reflect.Func {
+		return fmt.Errorf("Validate must be passed a function type for the invoke function, not %v", invokeType)
+	}
+	invokeFunc := reflect.New(invokeType).Interface()
+	var initFunc interface{}
+	if initType != nil {
+		if initType.Kind() != reflect.Func {
+			return fmt.Errorf("Validate must be passed a function type for the init function, not %s", initType)
+		}
+		initFunc = reflect.New(initType).Interface()
+	}
+
+	invokeF := newProvider(invokeFunc, -1, c.name+" invoke func")
+	var initF *provider
+	if initFunc != nil {
+		initF = newProvider(initFunc, -1, c.name+" initialization func")
+	}
+	options := newBindOptions(opts)
+	if options.asyncWorkers != 0 {
+		return errAsyncWorkers
+	}
+	if err := doBind(c, invokeF, initF, bindValidate, options); err != nil {
+		return c.detailedBindError(err, invokeFunc, initFunc, options)
+	}
+	return nil
 }
 
 // TODO: add an example
@@ -278,7 +509,7 @@ func (c *Collection) bindFast(invokeFunc interface{}, initFunc interface{}) erro
 // The second argument (if present) must be an init function.  The invoke func
 // (and the init func if present) will be created by SetCallback() and passed
 // to the function SetCallback calls.
-func (c *Collection) SetCallback(setCallbackFunc interface{}) error {
+func (c *Collection) SetCallback(setCallbackFunc interface{}, opts ...BindOption) error {
 	setter := reflect.ValueOf(setCallbackFunc)
 	setterType := setter.Type()
 	if setterType.Kind() != reflect.Func {
@@ -299,14 +530,14 @@ func (c *Collection) SetCallback(setCallbackFunc interface{}) error {
 	invokePtr := reflect.New(setterType.In(0)).Interface()
 	if setterType.NumIn() == 2 {
 		initPtr := reflect.New(setterType.In(1)).Interface()
-		err = c.Bind(invokePtr, initPtr)
+		err = c.Bind(invokePtr, initPtr, opts...)
 		if err == nil {
 			setter.Call([]reflect.Value{
 				reflect.ValueOf(invokePtr).Elem(),
 				reflect.ValueOf(initPtr).Elem()})
 		}
 	} else {
-		err = c.Bind(invokePtr, nil)
+		err = c.Bind(invokePtr, nil, opts...)
 		if err == nil {
 			setter.Call([]reflect.Value{reflect.ValueOf(invokePtr).Elem()})
 		}
@@ -370,16 +601,16 @@ func MustBindSimpleError(c *Collection, name string) func() error {
 }
 
 // MustBind is a wrapper for Collection.Bind().  It panic()s if Bind() returns error.
-func MustBind(c *Collection, invokeFunc interface{}, initFunc interface{}) {
-	err := c.Bind(invokeFunc, initFunc)
+func MustBind(c *Collection, invokeFunc interface{}, initFunc interface{}, opts ...BindOption) {
+	err := c.Bind(invokeFunc, initFunc, opts...)
 	if err != nil {
 		panic(DetailedError(err))
 	}
 }
 
 // MustSetCallback is a wrapper for Collection.SetCallback().  It panic()s if SetCallback() returns error.
-func MustSetCallback(c *Collection, binderFunction interface{}) {
-	err := c.SetCallback(binderFunction)
+func MustSetCallback(c *Collection, binderFunction interface{}, opts ...BindOption) {
+	err := c.SetCallback(binderFunction, opts...)
 	if err != nil {
 		panic(DetailedError(err))
 	}
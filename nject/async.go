@@ -0,0 +1,165 @@
+package nject
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// PanicError is returned by the result functions of BindAsync when
+// the provider chain panics.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("panic in provider chain: %v", e.Value)
+}
+
+// AsyncWorkers is a BindOption for BindAsync that limits the number of
+// invocations that run at the same time, and the number of goroutines
+// that run them, to n.  Invocations beyond n are queued until a worker
+// is free.  Calling the invoke function does not block.  Bind and
+// Validate return an error if they are given AsyncWorkers.
+//
+// AsyncWorkers(0) does not limit the invocations.  AsyncWorkers panics if
+// n is negative.
+func AsyncWorkers(n int) BindOption {
+	if n < 0 {
+		panic(fmt.Sprintf("AsyncWorkers requires a count that is not negative, not %d", n))
+	}
+	return func(o *bindOptions) {
+		o.asyncWorkers = n
+	}
+}
+
+var errAsyncWorkers = errors.New("AsyncWorkers can only be used with BindAsync")
+
+// workerPool runs jobs on at most n goroutines.  Workers are started
+// as needed and exit when there is nothing queued.
+type workerPool struct {
+	n       int
+	lock    sync.Mutex
+	running int
+	queue   []func()
+}
+
+func (p *workerPool) run(job func()) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.running < p.n {
+		p.running++
+		go p.work(job)
+		return
+	}
+	p.queue = append(p.queue, job)
+}
+
+func (p *workerPool) work(job func()) {
+	for {
+		job()
+		p.lock.Lock()
+		if len(p.queue) == 0 {
+			p.running--
+			p.lock.Unlock()
+			return
+		}
+		job = p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.lock.Unlock()
+	}
+}
+
+// BindAsync is like Bind except that the invoke function runs the
+// provider chain in a goroutine.  Instead of returning the values returned
+// by the chain, the invoke function returns a function that waits for the
+// chain to finish and then returns them.  That function must be of a named
+// type that takes no arguments:
+//
+//	type Result func() (string, error)
+//
+//	var dispatch func(Job) Result
+//	err := nject.Sequence("jobs", ...).BindAsync(&dispatch, nil)
+//	...
+//	result := dispatch(job)
+//	... do other things ...
+//	s, err := result()
+//
+// The result function can be called more than once and from more than
+// one goroutine.  TerminalErrors are returned through the result function
+// the same way they are returned by Bind's invoke function.  If the chain
+// panics, the result function returns PanicError as its error if it
+// returns error.  Otherwise the result function panics with the same value.
+//
+// STATIC chain initialization happens in the goroutine of the first
+// invocation unless the init function is called first.
+func (c *Collection) BindAsync(invokeFunc interface{}, initFunc interface{}, opts ...BindOption) error {
+	v := reflect.ValueOf(invokeFunc)
+	if v.Kind() != reflect.Ptr || v.Type().Elem().Kind() != reflect.Func {
+		return fmt.Errorf("BindAsync must be passed a pointer to a function, not %T", invokeFunc)
+	}
+	asyncType := v.Type().Elem()
+	if asyncType.NumOut() != 1 {
+		return fmt.Errorf("BindAsync invoke function %s must return a single function of a named type", asyncType)
+	}
+	resultType := asyncType.Out(0)
+	if resultType.Kind() != reflect.Func || resultType.Name() == "" || resultType.NumIn() != 0 {
+		return fmt.Errorf("BindAsync invoke function %s must return a function of a named type that takes no arguments, not %s", asyncType, resultType)
+	}
+	invokeType := reflect.FuncOf(typesIn(asyncType), typesOut(resultType), asyncType.IsVariadic())
+	invoke := reflect.New(invokeType)
+	options := newBindOptions(opts)
+	if err := c.bindFast(invoke.Interface(), initFunc, options); err != nil {
+		return c.detailedBindError(err, invoke.Interface(), initFunc, options)
+	}
+	invoke = invoke.Elem()
+
+	start := func(job func()) { go job() }
+	if options.asyncWorkers > 0 {
+		pool := &workerPool{n: options.asyncWorkers}
+		start = pool.run
+	}
+	errorIndex := typeIndex(typesOut(resultType), errorType)
+
+	v.Elem().Set(reflect.MakeFunc(asyncType, func(inputs []reflect.Value) []reflect.Value {
+		done := make(chan struct{})
+		var results []reflect.Value
+		var panicked bool
+		var panicValue interface{}
+		start(func() {
+			defer close(done)
+			defer func() {
+				if r := recover(); r != nil {
+					panicked = true
+					panicValue = r
+				}
+			}()
+			if invokeType.IsVariadic() {
+				results = invoke.CallSlice(inputs)
+			} else {
+				results = invoke.Call(inputs)
+			}
+		})
+		result := reflect.MakeFunc(resultType, func([]reflect.Value) []reflect.Value {
+			<-done
+			if !panicked {
+				return results
+			}
+			if errorIndex == -1 {
+				panic(panicValue)
+			}
+			out := make([]reflect.Value, resultType.NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(resultType.Out(i))
+			}
+			err := reflect.New(errorType).Elem()
+			err.Set(reflect.ValueOf(PanicError{Value: panicValue}))
+			out[errorIndex] = err
+			return out
+		})
+		return []reflect.Value{result}
+	}))
+	return nil
+}
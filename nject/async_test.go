@@ -0,0 +1,153 @@
+package nject
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type asyncResult func() (s2, error)
+type asyncValue func() s2
+
+func TestBindAsync(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var dispatch func(s1) asyncResult
+		require.NoError(t, Sequence("async",
+			func(s s1) (s1, TerminalError) {
+				if s == "fail" {
+					return "", errors.New("failed")
+				}
+				return s, nil
+			},
+			func(s s1) (s2, error) {
+				if s == "panic" {
+					panic("oops")
+				}
+				return s2(s) + "!", nil
+			},
+		).BindAsync(&dispatch, nil))
+
+		ok, fail, panicked := dispatch("ok"), dispatch("fail"), dispatch("panic")
+		got, err := ok()
+		require.NoError(t, err)
+		assert.Equal(t, s2("ok!"), got)
+		got, err = ok()
+		require.NoError(t, err, "result can be called again")
+		assert.Equal(t, s2("ok!"), got)
+
+		_, err = fail()
+		assert.EqualError(t, err, "failed")
+
+		_, err = panicked()
+		var panicError PanicError
+		require.True(t, errors.As(err, &panicError))
+		assert.Equal(t, "oops", panicError.Value)
+	})
+}
+
+func TestBindAsyncPanicWithoutError(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var dispatch func(s1) asyncValue
+		require.NoError(t, Sequence("async",
+			func(s s1) s2 {
+				if s == "panic" {
+					panic("oops")
+				}
+				return s2(s)
+			},
+		).BindAsync(&dispatch, nil))
+		assert.Equal(t, s2("ok"), dispatch("ok")())
+		result := dispatch("panic")
+		assert.PanicsWithValue(t, "oops", func() { result() })
+	})
+}
+
+func TestBindAsyncWorkers(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var running, maxRunning int32
+		var mu sync.Mutex
+		release := make(chan struct{})
+		var dispatch func(s1) asyncValue
+		require.NoError(t, Sequence("async",
+			func(s s1) s2 {
+				n := atomic.AddInt32(&running, 1)
+				mu.Lock()
+				if n > maxRunning {
+					maxRunning = n
+				}
+				mu.Unlock()
+				<-release
+				atomic.AddInt32(&running, -1)
+				return s2(s)
+			},
+		).BindAsync(&dispatch, nil, AsyncWorkers(2)))
+
+		results := make([]asyncValue, 6)
+		for i := range results {
+			results[i] = dispatch(s1(rune('a' + i)))
+		}
+		for atomic.LoadInt32(&running) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		// give the other invocations a chance to (wrongly) start
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		for i, result := range results {
+			assert.Equal(t, s2(rune('a'+i)), result())
+		}
+		assert.Equal(t, int32(2), maxRunning)
+	})
+}
+
+func TestAsyncWorkersNegative(t *testing.T) {
+	assert.Panics(t, func() { AsyncWorkers(-1) })
+	assert.NotPanics(t, func() { AsyncWorkers(0) })
+}
+
+func TestBindAsyncWorkerGoroutines(t *testing.T) {
+	release := make(chan struct{})
+	var dispatch func(s1) asyncValue
+	require.NoError(t, Sequence("async",
+		func(s s1) s2 {
+			<-release
+			return s2(s)
+		},
+	).BindAsync(&dispatch, nil, AsyncWorkers(2)))
+
+	before := runtime.NumGoroutine()
+	results := make([]asyncValue, 100)
+	for i := range results {
+		results[i] = dispatch("x")
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine()-before, 2, "goroutines for 100 queued invocations")
+	close(release)
+	for _, result := range results {
+		assert.Equal(t, s2("x"), result())
+	}
+}
+
+func TestAsyncWorkersOnlyForBindAsync(t *testing.T) {
+	c := Sequence("async", func(s s1) s2 { return s2(s) })
+	var invoke func(s1) s2
+	assert.Error(t, c.Bind(&invoke, nil, AsyncWorkers(2)))
+	assert.Error(t, c.Validate(reflect.TypeOf(invoke), nil, AsyncWorkers(2)))
+}
+
+func TestBindAsyncErrors(t *testing.T) {
+	c := Sequence("async", func(s s1) s2 { return s2(s) })
+	var notPointer func(s1) asyncValue
+	assert.Error(t, c.BindAsync(notPointer, nil))
+	var unnamed func(s1) func() s2
+	assert.Error(t, c.BindAsync(&unnamed, nil))
+	var twoResults func(s1) (asyncValue, error)
+	assert.Error(t, c.BindAsync(&twoResults, nil))
+	var wrongTypes func(s2) asyncValue
+	assert.Error(t, c.BindAsync(&wrongTypes, nil))
+}
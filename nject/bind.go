@@ -4,74 +4,132 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
-// When !real, do not actually bind.  !real is used for generating debug traces.
-func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider, real bool) error {
-	// Split up the collection into LITERAL, STATIC, RUN, and FINAL groups. Add
-	// init and invoke as faked providers.  Flatten into one ordered list.
-	var invokeIndex int
-	var invokeF *provider
-	var initF *provider
-	var debuggingProvider **provider
-	funcs := make([]*provider, 0, len(sc.contents)+3)
+type bindMode int
+
+const (
+	bindReal     bindMode = iota // bind the init and invoke functions
+	bindTrace                    // do everything but bind: used for generating debug traces
+	bindValidate                 // stop once the set of included providers is known
+)
+
+// flatChain is a provider chain that has been characterized and flattened
+// into one ordered list of providers
+type flatChain struct {
+	funcs             []*provider
+	invokeIndex       int
+	invokeF           *provider
+	initF             *provider
+	debuggingProvider *provider
+	// generationProvider is only added if something consumes *Generation
+	generationProvider *provider
+}
+
+// buildChain splits up the collection into LITERAL, STATIC, RUN, and FINAL groups.
+// It adds init and invoke as faked providers and flattens everything into one
+// ordered list.
+func (sc *Collection) buildChain(originalInvokeF *provider, originalInitF *provider, dbg *debugSink) (*flatChain, error) {
+	ch := &flatChain{
+		funcs: make([]*provider, 0, len(sc.contents)+3),
+	}
+	var err error
+	ch.invokeF, err = characterizeInitInvoke(originalInvokeF, charContext{inputsAreStatic: false})
+	if err != nil {
+		return nil, err
+	}
+	if ch.invokeF.flows == nil {
+		return nil, fmt.Errorf("internal error #4: no flows for invoke")
+	}
+	nonStaticTypes := make(map[typeCode]bool)
+	for _, tc := range ch.invokeF.flows[outputParams] {
+		nonStaticTypes[tc] = true
+	}
+
+	beforeInvoke, afterInvoke, err := sc.characterizeAndFlatten(nonStaticTypes, dbg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add debugging provider
 	{
-		var err error
-		invokeF, err = characterizeInitInvoke(originalInvokeF, charContext{inputsAreStatic: false})
+		d := newProvider(func() *Debugging { return nil }, -1, "Debugging")
+		d.cacheable = true
+		d.mustCache = true
+		d, err = characterizeFunc(d, charContext{inputsAreStatic: true})
 		if err != nil {
-			return err
-		}
-		if invokeF.flows == nil {
-			return fmt.Errorf("internal error #4: no flows for invoke")
-		}
-		nonStaticTypes := make(map[typeCode]bool)
-		for _, tc := range invokeF.flows[outputParams] {
-			nonStaticTypes[tc] = true
+			return nil, fmt.Errorf("internal error #29: problem with debugging injectors: %s", err)
 		}
+		d.isSynthetic = true
+		ch.debuggingProvider = d
+		ch.funcs = append(ch.funcs, d)
+	}
 
-		beforeInvoke, afterInvoke, err := sc.characterizeAndFlatten(nonStaticTypes)
+	// Add generation provider
+	if consumesType(generationTypeCode, beforeInvoke, afterInvoke) {
+		g := newProvider(func() *Generation { return nil }, -1, "Generation")
+		g.cacheable = true
+		g.mustCache = true
+		g, err = characterizeFunc(g, charContext{inputsAreStatic: true})
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("internal error #30: problem with generation injector: %s", err)
 		}
+		g.isSynthetic = true
+		ch.generationProvider = g
+		ch.funcs = append(ch.funcs, g)
+	}
 
-		// Add debugging provider
-		{
-			d := newProvider(func() *Debugging { return nil }, -1, "Debugging")
-			d.cacheable = true
-			d.mustCache = true
-			d, err = characterizeFunc(d, charContext{inputsAreStatic: true})
-			if err != nil {
-				return fmt.Errorf("internal error #29: problem with debugging injectors: %s", err)
-			}
-			d.isSynthetic = true
-			debuggingProvider = &d
-			funcs = append(funcs, d)
+	// Add init
+	if originalInitF != nil {
+		ch.initF, err = characterizeInitInvoke(originalInitF, charContext{inputsAreStatic: true})
+		if err != nil {
+			return nil, err
 		}
-
-		// Add init
-		if originalInitF != nil {
-			initF, err = characterizeInitInvoke(originalInitF, charContext{inputsAreStatic: true})
-			if err != nil {
-				return err
-			}
-			if initF.flows == nil {
-				return fmt.Errorf("internal error #5: no flows for initF")
-			}
-			funcs = append(funcs, initF)
+		if ch.initF.flows == nil {
+			return nil, fmt.Errorf("internal error #5: no flows for initF")
 		}
+		ch.funcs = append(ch.funcs, ch.initF)
+	}
 
-		funcs = append(funcs, beforeInvoke...)
-		invokeIndex = len(funcs)
-		funcs = append(funcs, invokeF)
-		funcs = append(funcs, afterInvoke...)
+	ch.funcs = append(ch.funcs, beforeInvoke...)
+	ch.invokeIndex = len(ch.funcs)
+	ch.funcs = append(ch.funcs, ch.invokeF)
+	ch.funcs = append(ch.funcs, afterInvoke...)
 
-		for i, fm := range funcs {
-			fm.chainPosition = i
-			if fm.required {
-				fm.include = true
+	for i, fm := range ch.funcs {
+		fm.chainPosition = i
+		if fm.required {
+			fm.include = true
+		}
+	}
+	return ch, nil
+}
+
+func consumesType(tc typeCode, lists ...[]*provider) bool {
+	for _, list := range lists {
+		for _, fm := range list {
+			for _, in := range fm.flows[inputParams] {
+				if in == tc {
+					return true
+				}
 			}
 		}
 	}
+	return false
+}
+
+func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider, mode bindMode, options bindOptions) error {
+	dbg := options.debug
+	ch, err := sc.buildChain(originalInvokeF, originalInitF, dbg)
+	if err != nil {
+		return err
+	}
+	funcs := ch.funcs
+	invokeIndex := ch.invokeIndex
+	invokeF := ch.invokeF
+	initF := ch.initF
+	debuggingProvider := ch.debuggingProvider
 
 	// Figure out which providers must be included in the final chain.  To do this,
 	// first we figure out where each provider will get its inputs from when going
@@ -94,11 +152,18 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 
 	// Compute dependencies: set fm.downRmap, fm.upRmap, fm.cannotInclude,
 	// fm.whyIncluded, fm.include
-	err := computeDependenciesAndInclusion(funcs, initF)
+	err = computeDependenciesAndInclusion(funcs, initF, dbg)
+	if err != nil {
+		return err
+	}
+
+	err = checkStrict(funcs, options)
 	if err != nil {
 		return err
 	}
 
+	suppressDefaultOutputs(funcs)
+
 	// Build the lists of parameters that are included in the value collections.
 	// These are maps from types to position in the value collection.
 	//
@@ -147,13 +212,31 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 		fm.downVmapCount = downCount
 		addToVmap(fm, inputParams, downVmap, fm.downRmap, &downCount)
 		fm.upVmapCount = upCount
-		addToVmap(fm, returnParams, upVmap, fm.upRmap, &upCount)
+		addToVmap(fm, returnParams, upVmap, returnRmap(fm), &upCount)
 		fm.mustZeroIfInnerNotCalled = vmapMapped(upVmap)
 	}
+	// When the invoke function takes an *InvokeTrace, it is used by the
+	// generated wrappers even when no provider consumes it.
+	if invokeF.invokeTakes(invokeTraceTypeCode) && downVmap[invokeTraceTypeCode] == -1 {
+		downVmap[invokeTraceTypeCode] = downCount
+		downCount++
+	}
+
+	if mode == bindValidate {
+		return nil
+	}
+
+	// gens is set once baseValues exists
+	var gens *generations
+	if ch.generationProvider != nil && ch.generationProvider.include {
+		ch.generationProvider.fn = func() *Generation {
+			return gens.generation()
+		}
+	}
 
 	// Fill in debugging (if used)
-	if (*debuggingProvider).include {
-		(*debuggingProvider).fn = func() *Debugging {
+	if debuggingProvider.include {
+		debuggingProvider.fn = func() *Debugging {
 			included := make([]string, 0, len(funcs)+3)
 			for _, fm := range funcs {
 				if fm.include {
@@ -164,11 +247,7 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 			namesIncluded := make([]string, 0, len(funcs)+3)
 			for _, fm := range funcs {
 				if fm.include {
-					if fm.index >= 0 {
-						namesIncluded = append(namesIncluded, fmt.Sprintf("%s(%d)", fm.origin, fm.index))
-					} else {
-						namesIncluded = append(namesIncluded, fm.origin)
-					}
+					namesIncluded = append(namesIncluded, fm.shortName())
 				}
 			}
 
@@ -182,39 +261,55 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 			}
 
 			var trace string
-			if debugEnabled() {
+			if dbg.enabled() {
 				trace = "debugging already in progress"
 			} else {
-				trace = captureDoBindDebugging(sc, originalInvokeF, originalInitF)
+				trace = captureDoBindDebugging(sc, originalInvokeF, originalInitF, options)
 			}
 
 			reproduce := generateReproduce(funcs, invokeF, initF)
 
+			providers := make([]ProviderInfo, 0, len(funcs)+3)
+			for _, fm := range funcs {
+				providers = append(providers, fm.info())
+			}
+
 			return &Debugging{
 				Included:       included,
 				NamesIncluded:  namesIncluded,
 				IncludeExclude: includeExclude,
 				Trace:          trace,
 				Reproduce:      reproduce,
+				Providers:      providers,
+				reproduceFile: func(pkgName string) string {
+					return generateReproduceFile(pkgName, funcs, invokeF, initF, true)
+				},
 			}
 		}
 	}
-	if debugEnabled() {
+	if dbg.enabled() {
 		for _, fm := range funcs {
-			dumpF("funclist", fm)
+			dbg.dumpF("funclist", fm)
 		}
 	}
 
-	// Generate wrappers and split the handlers into groups (static, middleware, final)
+	deferLazyDependencies(funcs)
+
+	// Generate wrappers and split the handlers into groups (static, middleware, final).
+	// Deferred providers are run by the Lazy provider that needs them, not by the chain.
+	adapting := adaptsPointers(funcs)
 	collections := make(map[groupType][]*provider)
 	for _, fm := range funcs {
 		if !fm.include {
 			continue
 		}
-		err := generateWrappers(fm, downVmap, upVmap, upCount)
+		err := generateWrappers(fm, downVmap, upVmap, upCount, adapting, dbg)
 		if err != nil {
 			return err
 		}
+		if fm.deferred {
+			continue
+		}
 		collections[fm.group] = append(collections[fm.group], fm)
 	}
 	if len(collections[finalGroup]) != 1 {
@@ -271,21 +366,25 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 	// collection will be copied.
 	baseValues := make(valueCollection, downCount)
 	for _, lit := range collections[literalGroup] {
-		i := downVmap[lit.flows[outputParams][0]]
-		if i >= 0 {
+		tc := lit.flows[outputParams][0]
+		i := downVmap[tc]
+		if i >= 0 && !lit.suppressedOutputs[tc] {
 			baseValues[i] = reflect.ValueOf(lit.fn)
 		}
 	}
 
 	// Generate static chain function
-	runStaticChain := func() error {
-		debugf("STATIC CHAIN LENGTH: %d", len(collections[staticGroup]))
+	runStaticChain := func(values valueCollection) (err error) {
+		if adapting {
+			defer recoverNilPointer(&err)
+		}
+		dbg.debugf("STATIC CHAIN LENGTH: %d", len(collections[staticGroup]))
 		for _, inj := range collections[staticGroup] {
-			debugf("STATIC CHAIN CALLING %s", inj)
+			dbg.debugf("STATIC CHAIN CALLING %s", inj)
 
-			err := inj.wrapStaticInjector(baseValues)
+			err := inj.wrapStaticInjector(values)
 			if err != nil {
-				debugf("STATIC CHAIN RETURNING EARLY DUE TO ERROR %s", err)
+				dbg.debugf("STATIC CHAIN RETURNING EARLY DUE TO ERROR %s", err)
 				return err
 			}
 		}
@@ -298,77 +397,130 @@ func doBind(sc *Collection, originalInvokeF *provider, originalInitF *provider,
 	}
 
 	// Generate and bind init func.
+	gens = newGenerations(baseValues)
+	state := newInitState(baseValues, options.retryableInit, gens.initialized, gens.abandoned)
 	initFunc := func() {}
-	var initOnce sync.Once
 	if initF != nil {
-		outMap, err := generateOutputMapper(initF, 0, outputParams, downVmap, "init inputs")
+		outMap, err := generateOutputMapper(initF, 0, outputParams, downVmap, "init inputs", dbg)
 		if err != nil {
 			return err
 		}
 
-		inMap, err := generateInputMapper(initF, 0, bypassParams, initF.bypassRmap, downVmap, "init results")
+		inMap, err := generateInputMapper(initF, 0, bypassParams, initF.bypassRmap, downVmap, "init results", dbg)
 		if err != nil {
 			return err
 		}
 
-		debugln("SET INIT FUNC")
-		if real {
+		dbg.debugln("SET INIT FUNC")
+		if mode == bindReal {
 			reflect.ValueOf(initF.fn).Elem().Set(
 				reflect.MakeFunc(reflect.ValueOf(initF.fn).Type().Elem(),
 					func(inputs []reflect.Value) []reflect.Value {
-						debugln("INSIDE INIT")
+						dbg.debugln("INSIDE INIT")
 						// if initDone panic, return error, or ignore?
-						initOnce.Do(func() {
-							outMap(baseValues, inputs)
-							debugln("RUN STATIC CHAIN")
-							_ = runStaticChain()
-						})
-						dumpValueArray(baseValues, "base values before init return", downVmap)
-						out := inMap(baseValues)
-						debugln("DONE INIT")
-						dumpValueArray(out, "init return", nil)
-						dumpF("init", initF)
+						_ = state.run(func(values valueCollection) {
+							outMap(values, inputs)
+							dbg.debugln("RUN STATIC CHAIN")
+						}, runStaticChain)
+						values := state.values()
+						dbg.dumpValueArray(values, "base values before init return", downVmap)
+						out := inMap(values)
+						dbg.debugln("DONE INIT")
+						dbg.dumpValueArray(out, "init return", nil)
+						dbg.dumpF("init", initF)
 
 						return out
 					}))
 		}
-		debugln("SET INIT FUNC - DONE")
+		dbg.debugln("SET INIT FUNC - DONE")
 
 	} else {
 		initFunc = func() {
-			initOnce.Do(func() {
-				_ = runStaticChain()
-			})
+			_ = state.run(func(valueCollection) {}, runStaticChain)
 		}
 	}
 
 	// Generate and bind invoke func
 	{
-		outMap, err := generateOutputMapper(invokeF, 0, outputParams, downVmap, "invoke inputs")
+		outMap, err := generateOutputMapper(invokeF, 0, outputParams, downVmap, "invoke inputs", dbg)
 		if err != nil {
 			return err
 		}
 
-		inMap, err := generateInputMapper(invokeF, 0, returnedParams, invokeF.upRmap, upVmap, "invoke results")
+		inMap, err := generateInputMapper(invokeF, 0, returnedParams, invokeF.upRmap, upVmap, "invoke results", dbg)
 		if err != nil {
 			return err
 		}
 
-		debugln("SET INVOKE FUNC")
-		if real {
+		invokeType := reflect.ValueOf(invokeF.fn).Type().Elem()
+		errorIndex := typeIndex(typesOut(invokeType), errorType)
+		// If the init func returns error, then STATIC chain failures
+		// have already been reported.  If the RUN chain consumes the
+		// error from the STATIC chain, it handles them.  Otherwise they
+		// are reported by invoke.
+		surfaceInitError := initF == nil || typeIndex(typesOut(reflect.ValueOf(initF.fn).Type().Elem()), errorType) == -1
+		for _, fm := range funcs[invokeIndex:] {
+			if fm.include && fm.takesInput(getTypeCode(errorType)) {
+				surfaceInitError = false
+			}
+		}
+		errorReturn := func(err error) []reflect.Value {
+			out := make([]reflect.Value, invokeType.NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(invokeType.Out(i))
+			}
+			out[errorIndex] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+
+		dbg.debugln("SET INVOKE FUNC")
+		if mode == bindReal {
 			reflect.ValueOf(invokeF.fn).Elem().Set(
-				reflect.MakeFunc(reflect.ValueOf(invokeF.fn).Type().Elem(),
+				reflect.MakeFunc(invokeType,
 					func(inputs []reflect.Value) []reflect.Value {
 						initFunc()
-						values := baseValues.Copy()
-						dumpValueArray(values, "invoke - before input copy", downVmap)
+						if err := state.err(); err != nil && surfaceInitError {
+							// The STATIC chain failed so the values that
+							// the RUN chain needs are missing.
+							if errorIndex == -1 {
+								panic(fmt.Sprintf("cannot invoke %s: initialization failed: %s", sc.name, err))
+							}
+							return errorReturn(err)
+						}
+						var values valueCollection
+						if options.reload != nil {
+							g, current := gens.acquire()
+							defer gens.release(g)
+							values = current.Copy()
+						} else {
+							values = state.values().Copy()
+						}
+						dbg.dumpValueArray(values, "invoke - before input copy", downVmap)
 						outMap(values, inputs)
-						dumpValueArray(values, "invoke - after input copy", downVmap)
-						ret := f(values)
+						dbg.dumpValueArray(values, "invoke - after input copy", downVmap)
+						if !adapting {
+							return inMap(f(values))
+						}
+						var ret valueCollection
+						var nilPointer error
+						func() {
+							defer recoverNilPointer(&nilPointer)
+							ret = f(values)
+						}()
+						if nilPointer != nil {
+							if errorIndex == -1 {
+								panic(fmt.Sprintf("cannot invoke %s: %s", sc.name, nilPointer))
+							}
+							return errorReturn(nilPointer)
+						}
 						return inMap(ret)
 					}))
 		}
-		debugln("SET INVOKE FUNC - DONE")
+		dbg.debugln("SET INVOKE FUNC - DONE")
+	}
+
+	if mode == bindReal && options.reload != nil {
+		*options.reload = gens.makeReload(state, runStaticChain)
 	}
 
 	return nil
@@ -384,6 +536,62 @@ func vmapMapped(vMap map[typeCode]int) []typeCode {
 	return used
 }
 
+// takesInput is true if tc is one of the inputs of fm
+func (fm *provider) takesInput(tc typeCode) bool {
+	for _, input := range fm.flows[inputParams] {
+		if input == tc {
+			return true
+		}
+	}
+	return false
+}
+
+// invokeTakes is true if the invoke function fm takes tc as an argument.
+// The arguments of an invoke function are its outputParams.
+func (fm *provider) invokeTakes(tc typeCode) bool {
+	for _, output := range fm.flows[outputParams] {
+		if output == tc {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefaultFor is true if fm only provides tc as a fallback
+func (fm *provider) isDefaultFor(tc typeCode) bool {
+	if fm.isDefault {
+		return true
+	}
+	if len(fm.defaultTypes) == 0 {
+		return false
+	}
+	_, public := tc.scope()
+	return fm.defaultTypes[public]
+}
+
+// suppressDefaultOutputs marks the outputs of Default providers that
+// would overwrite values from earlier non-Default providers.
+func suppressDefaultOutputs(funcs []*provider) {
+	provided := make(map[typeCode]bool)
+	for _, fm := range funcs {
+		if !fm.include {
+			continue
+		}
+		for _, tc := range fm.flows[outputParams] {
+			if !fm.isDefaultFor(tc) {
+				provided[tc] = true
+				continue
+			}
+			if provided[tc] {
+				if fm.suppressedOutputs == nil {
+					fm.suppressedOutputs = make(map[typeCode]bool)
+				}
+				fm.suppressedOutputs[tc] = true
+			}
+		}
+	}
+}
+
 func addToVmap(fm *provider, param flowType, vMap map[typeCode]int, rMap map[typeCode]typeCode, counter *int) {
 	for _, tc := range fm.flows[param] {
 		if rm, found := rMap[tc]; found {
@@ -395,3 +603,95 @@ func addToVmap(fm *provider, param flowType, vMap map[typeCode]int, rMap map[typ
 		}
 	}
 }
+
+// returnRmap returns the part of fm.upRmap that applies to the values
+// that fm returns.  When the values returned to fm were converted or
+// were matched to a Loose interface, fm returns a different value of its
+// own type so that is how it is keyed.
+func returnRmap(fm *provider) map[typeCode]typeCode {
+	rMap := make(map[typeCode]typeCode, len(fm.upRmap))
+	for in, found := range fm.upRmap {
+		if found == in || (!fm.loose && !isConversion(in, found)) {
+			rMap[in] = found
+		}
+	}
+	return rMap
+}
+
+// initState tracks running the STATIC chain.  The STATIC chain runs
+// once unless it fails and the binding has the RetryableInit option.
+//
+// Each run starts from a copy of the initial values.  The values are
+// only published when the run is complete so that invocations running
+// at the same time as a retry never see a partial set of values.
+type initState struct {
+	lock      sync.Mutex
+	done      uint32
+	result    atomic.Value // initResult
+	retryable bool
+	initial   valueCollection // base values before the STATIC chain runs
+	inputs    valueCollection // base values with the init inputs, before the STATIC chain runs
+	published func(valueCollection)
+	abandoned func() // called when a run fails and will be retried
+}
+
+type initResult struct {
+	err    error
+	values valueCollection
+}
+
+func newInitState(baseValues valueCollection, retryable bool, published func(valueCollection), abandoned func()) *initState {
+	s := &initState{
+		retryable: retryable,
+		initial:   baseValues,
+		published: published,
+		abandoned: abandoned,
+	}
+	s.result.Store(initResult{values: baseValues})
+	return s
+}
+
+// values returns the values from the most recent run of the STATIC chain
+// or the initial values if it has not run.  They must not be modified.
+func (s *initState) values() valueCollection {
+	return s.result.Load().(initResult).values
+}
+
+// err returns the error from the most recent run of the STATIC chain
+func (s *initState) err() error {
+	return s.result.Load().(initResult).err
+}
+
+// succeeded returns the inputs to the STATIC chain if it has succeeded
+func (s *initState) succeeded() (valueCollection, bool) {
+	if atomic.LoadUint32(&s.done) == 0 || s.err() != nil {
+		return nil, false
+	}
+	return s.inputs, true
+}
+
+func (s *initState) run(setInputs func(valueCollection), runStaticChain func(valueCollection) error) error {
+	if atomic.LoadUint32(&s.done) == 1 {
+		return s.err()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done == 1 {
+		return s.err()
+	}
+	values := s.initial.Copy()
+	setInputs(values)
+	inputs := values.Copy()
+	err := runStaticChain(values)
+	// A failed run is published too: the RUN chain may consume
+	// the error from a fallible STATIC injector.
+	s.inputs = inputs
+	s.published(values)
+	s.result.Store(initResult{err: err, values: values})
+	if err == nil || !s.retryable {
+		atomic.StoreUint32(&s.done, 1)
+	} else {
+		s.abandoned()
+	}
+	return err
+}
@@ -8,6 +8,7 @@ package nject
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -565,3 +566,47 @@ func TestErrorRequiredNotBlockedByTerminalErrorFail(t *testing.T) {
 		assert.Error(t, ternbbte.Bind(&i2, nil))
 	})
 }
+
+func TestValidate(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called bool
+		c := Sequence("validate",
+			Cacheable(func(s s0) s1 {
+				called = true
+				return s1(s)
+			}),
+			func(s s1, x s2) s3 {
+				called = true
+				return s3(s) + s3(x)
+			},
+		)
+		for _, test := range []struct {
+			invoke reflect.Type
+			init   reflect.Type
+			valid  bool
+		}{
+			{reflect.TypeOf((func(s0, s2) s3)(nil)), nil, true},
+			{reflect.TypeOf((func(s2) s3)(nil)), reflect.TypeOf((func(s0))(nil)), true},
+			{reflect.TypeOf((func(s2) s3)(nil)), nil, false},
+			{reflect.TypeOf((func(s0, s2))(nil)), nil, false},
+			{reflect.TypeOf(""), nil, false},
+			{nil, nil, false},
+		} {
+			err := c.Validate(test.invoke, test.init)
+			if test.invoke != nil && test.invoke.Kind() == reflect.Func {
+				var initFunc interface{}
+				if test.init != nil {
+					initFunc = reflect.New(test.init).Interface()
+				}
+				bindErr := c.Bind(reflect.New(test.invoke).Interface(), initFunc)
+				assert.Equal(t, bindErr == nil, err == nil, "Validate and Bind agree %v %v", test.invoke, test.init)
+			}
+			if test.valid {
+				assert.NoError(t, err, "%v %v", test.invoke, test.init)
+			} else {
+				assert.Error(t, err, "%v %v", test.invoke, test.init)
+			}
+		}
+		assert.False(t, called)
+	})
+}
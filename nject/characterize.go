@@ -134,6 +134,7 @@ var inStatic = predicate("is after invoke", func(a testArgs) bool { return a.cc.
 var hasOutputs = predicate("does not have outputs", func(a testArgs) bool { return a.v.Type().NumOut() != 0 })
 var mustNotMemoize = predicate("is marked Memoized", func(a testArgs) bool { return !a.fm.memoize })
 var markedMemoized = predicate("is not marked Memoized", func(a testArgs) bool { return a.fm.memoize })
+var notTimeoutInjector = predicate("is marked Timeout, which only works for injectors and wrappers", func(a testArgs) bool { return !a.fm.timeoutInjector })
 var markedCacheable = predicate("is not marked Cacheable", func(a testArgs) bool { return a.fm.cacheable })
 var notMarkedNoCache = predicate("is marked NotCacheable", func(a testArgs) bool { return !a.fm.notCacheable })
 var mappableInputs = predicate("has inputs that cannot be map keys", func(a testArgs) bool { return mappable(typesIn(a.v.Type())...) })
@@ -362,6 +363,7 @@ var handlerRegistry = typeRegistry{
 			noAnonymousFuncs,
 			mustNotMemoize,
 			unstaticOkay,
+			notTimeoutInjector,
 		},
 		mutate: func(a testArgs) {
 			a.fm.group = finalGroup
@@ -394,6 +396,7 @@ Match:
 		a.fm.downRmap = make(map[typeCode]typeCode)
 		a.fm.flows = make(flowMapType)
 		match.mutate(a)
+		a.fm.scopeFlows()
 		return a.fm, nil
 	}
 
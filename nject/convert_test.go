@@ -0,0 +1,104 @@
+package nject
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userID string
+type accountOwnerID string
+type accountCount int
+
+func TestConvertibleTo(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got accountOwnerID
+		err := Run("convert",
+			ConvertibleTo([]reflect.Type{reflect.TypeOf(accountOwnerID(""))}, func() userID { return "u1" }),
+			func(a accountOwnerID) { got = a },
+		)
+		require.NoError(t, err)
+		assert.Equal(t, accountOwnerID("u1"), got)
+	})
+}
+
+func TestConvertibleToNotDeclared(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		err := Run("not declared",
+			ConvertibleTo([]reflect.Type{reflect.TypeOf(s1(""))}, func() userID { return "u1" }),
+			func(a accountOwnerID) {},
+		)
+		assert.Error(t, err)
+	})
+}
+
+func TestAutoConvert(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got accountOwnerID
+		var count accountCount
+		err := Run("auto",
+			AutoConvert(func() (userID, int) { return "u2", 3 }),
+			func(a accountOwnerID, c accountCount) {
+				got = a
+				count = c
+			},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, accountOwnerID("u2"), got)
+		assert.Equal(t, accountCount(3), count)
+	})
+}
+
+func TestAutoConvertKindMustMatch(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		// int can be converted to string in go but it is not the same underlying type
+		err := Run("kinds",
+			AutoConvert(func() int { return 65 }),
+			func(a accountOwnerID) {},
+		)
+		assert.Error(t, err)
+	})
+}
+
+func TestConvertExactMatchPreferred(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got accountOwnerID
+		err := Run("exact",
+			accountOwnerID("exact"),
+			AutoConvert(userID("converted")),
+			func(a accountOwnerID) { got = a },
+		)
+		require.NoError(t, err)
+		assert.Equal(t, accountOwnerID("exact"), got)
+	})
+}
+
+func TestConvertReturned(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() accountOwnerID
+		require.NoError(t, Sequence("returned",
+			func(inner func() accountOwnerID) accountOwnerID { return inner() + "!" },
+			AutoConvert(func() userID { return "u3" }),
+		).Bind(&invoke, nil))
+		assert.Equal(t, accountOwnerID("u3!"), invoke())
+	})
+}
+
+func TestConvertDebugging(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var d *Debugging
+		err := Run("debugging",
+			AutoConvert(userID("u4")),
+			func(a accountOwnerID, dbg *Debugging) { d = dbg },
+		)
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		var conversions []string
+		for _, pi := range d.Providers {
+			conversions = append(conversions, pi.Conversions...)
+		}
+		assert.Equal(t, []string{"nject.userID -> nject.accountOwnerID"}, conversions)
+	})
+}
@@ -1,85 +1,111 @@
 package nject
 
 import (
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
 )
 
-var debugLock sync.RWMutex
-var debug uint32
-var debugOutput string
-var debugOutputMu sync.Mutex
+// debugSink collects the debugging trace for a single Bind.  A nil
+// *debugSink is valid and discards everything so the debug methods can
+// be called without checking first.
+type debugSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newDebugSink(w io.Writer) *debugSink {
+	return &debugSink{w: w}
+}
 
-var debuglnHook func(...interface{})
-var debugfHook func(string, ...interface{})
+// globalDebug is used by binds that do not have their own debugSink.
+// It is only set by tests.
+var globalDebug atomic.Value
 
-func debugEnabled() bool {
-	return atomic.LoadUint32(&debug) == 1
+func init() {
+	globalDebug.Store((*debugSink)(nil))
 }
 
-func debugln(stuff ...interface{}) {
-	if !debugEnabled() {
+func globalDebugSink() *debugSink {
+	return globalDebug.Load().(*debugSink)
+}
+
+func (d *debugSink) enabled() bool {
+	return d != nil
+}
+
+func (d *debugSink) debugln(stuff ...interface{}) {
+	if d == nil {
 		return
 	}
-
-	debugOutputMu.Lock()
-	if debuglnHook != nil {
-		debuglnHook(stuff...)
-	} else {
-		for _, s := range stuff {
-			debugOutput += fmt.Sprint(s)
-		}
-		debugOutput += "\n"
+	var out string
+	for _, s := range stuff {
+		out += fmt.Sprint(s)
 	}
-	debugOutputMu.Unlock()
+	d.write(out + "\n")
 }
 
-func debugf(format string, stuff ...interface{}) {
-	if !debugEnabled() {
+func (d *debugSink) debugf(format string, stuff ...interface{}) {
+	if d == nil {
 		return
 	}
+	d.write(fmt.Sprintf(format+"\n", stuff...))
+}
 
-	debugOutputMu.Lock()
-	if debugfHook != nil {
-		debugfHook(format, stuff...)
-	} else {
-		debugOutput += fmt.Sprintf(format+"\n", stuff...)
-	}
-	debugOutputMu.Unlock()
+func (d *debugSink) write(s string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = io.WriteString(d.w, s)
 }
 
-func captureDoBindDebugging(sc *Collection, invokeF *provider, initF *provider) string {
-	debugLock.Lock()
-	if atomic.SwapUint32(&debug, 1) == 1 {
-		return "already capturing"
-	}
-	defer func() {
-		atomic.StoreUint32(&debug, 0)
-		debugLock.Unlock()
-	}()
+func debugln(stuff ...interface{}) {
+	globalDebugSink().debugln(stuff...)
+}
+
+func debugf(format string, stuff ...interface{}) {
+	globalDebugSink().debugf(format, stuff...)
+}
+
+// captureDoBindDebugging re-does a bind, without actually binding,
+// to capture the debug trace.  Each capture has its own debugSink so
+// captures do not block other binds.  The bind is re-done with the same
+// options so that failures caused by options show up in the trace.
+func captureDoBindDebugging(sc *Collection, invokeF *provider, initF *provider, options bindOptions) string {
+	var trace strings.Builder
+	dbg := newDebugSink(&trace)
 
-	debugOutputMu.Lock()
-	debugOutput = ""
-	debugOutputMu.Unlock()
+	options.debug = dbg
+	if options.strictWarnings != nil {
+		// the warnings were already written by the bind being traced
+		options.strictWarnings = ioutil.Discard
+	}
+	_ = doBind(sc, invokeF, initF, bindTrace, options)
 
-	_ = doBind(sc, invokeF, initF, false)
+	reproduce := generateReproduce(characterizeForReproduce(sc), invokeF, initF)
+	dbg.mu.Lock()
+	defer dbg.mu.Unlock()
+	return trace.String() + "\n\n\n" + reproduce
+}
 
+// characterizeForReproduce is used when Bind has failed and so
+// the providers have not been fully characterized.
+func characterizeForReproduce(sc *Collection) []*provider {
 	funcs := make([]*provider, len(sc.contents))
 	for i, f := range sc.contents {
 		funcs[i], _ = characterizeFunc(f, charContext{inputsAreStatic: true})
 	}
-	reproduce := generateReproduce(funcs, invokeF, initF)
-	debugOutputMu.Lock()
-	debugStr := debugOutput + "\n\n\n" + reproduce
-	debugOutputMu.Unlock()
-	return debugStr
+	return funcs
 }
 
-func dumpValueArray(va []reflect.Value, context string, vMap map[typeCode]int) {
-	if !debugEnabled() {
+func (d *debugSink) dumpValueArray(va []reflect.Value, context string, vMap map[typeCode]int) {
+	if d == nil {
 		return
 	}
 	if len(vMap) > 0 {
@@ -90,18 +116,18 @@ func dumpValueArray(va []reflect.Value, context string, vMap map[typeCode]int) {
 
 		for i, v := range va {
 			if v.IsValid() {
-				debugf("value at %s: %d: %s: %s: %v", context, i, reverseMap[i], v.Type(), v.Interface())
+				d.debugf("value at %s: %d: %s: %s: %v", context, i, reverseMap[i], v.Type(), v.Interface())
 			} else {
-				debugf("value at %s: %d: %s: UNINITIALIZED", context, i, reverseMap[i])
+				d.debugf("value at %s: %d: %s: UNINITIALIZED", context, i, reverseMap[i])
 			}
 		}
 		return
 	}
 	for i, v := range va {
 		if v.IsValid() {
-			debugf("value at %s: %d: %s: %v", context, i, v.Type(), v.Interface())
+			d.debugf("value at %s: %d: %s: %v", context, i, v.Type(), v.Interface())
 		} else {
-			debugf("value at %s: %d: UNINITIALIZED", context, i)
+			d.debugf("value at %s: %d: UNINITIALIZED", context, i)
 		}
 	}
 }
@@ -119,8 +145,8 @@ func dumpVmap(context string, vMap map[typeCode]int) {
 
 */
 
-func dumpF(context string, fm *provider) {
-	if !debugEnabled() {
+func (d *debugSink) dumpF(context string, fm *provider) {
+	if d == nil {
 		return
 	}
 	var out string
@@ -128,7 +154,7 @@ func dumpF(context string, fm *provider) {
 	out += fmt.Sprintf("\n\tclass: %s\n\tgroup: %s", fm.class, fm.group)
 	for name, flow := range fm.flows {
 		if len(flow) > 0 {
-			out += fmt.Sprintf("\n\t%s flow: %s", name, formatFlow(flow))
+			out += fmt.Sprintf("\n\t%s flow: %s", name, d.formatFlow(flow))
 		}
 	}
 	for upDown, rMap := range map[string]map[typeCode]typeCode{
@@ -170,11 +196,79 @@ func dumpF(context string, fm *provider) {
 	for _, dep := range fm.d.usedBy {
 		out += fmt.Sprintf("\n\tUSED BY: %s", dep)
 	}
-	debugln(out)
+	d.debugln(out)
+}
+
+// info creates the structured description of a provider
+// that is used for Debugging.Providers
+func (fm *provider) info() ProviderInfo {
+	pi := ProviderInfo{
+		Name:     fm.shortName(),
+		Path:     fm.fullName(),
+		Location: fm.location,
+		Index:    fm.index,
+		Class:    string(fm.class),
+		Group:    string(fm.group),
+		Included: fm.include,
+	}
+	if fm.include {
+		pi.Reason = fm.whyIncluded
+	} else if fm.cannotInclude != nil {
+		pi.Reason = fm.cannotInclude.Error()
+	}
+	for name, flow := range fm.flows {
+		if len(flow) == 0 {
+			continue
+		}
+		if pi.Flows == nil {
+			pi.Flows = make(map[string][]string)
+		}
+		types := make([]string, 0, len(flow))
+		for _, tc := range flow {
+			if tc == noTypeCode {
+				continue
+			}
+			types = append(types, tc.String())
+		}
+		pi.Flows[string(name)] = types
+	}
+	for _, c := range []struct {
+		param flowType
+		rmap  map[typeCode]typeCode
+	}{
+		{inputParams, fm.downRmap},
+		{returnedParams, fm.upRmap},
+	} {
+		for _, tc := range fm.flows[c.param] {
+			if tc == noTypeCode {
+				continue
+			}
+			from, found := c.rmap[tc]
+			if !found || from.Type() == tc.Type() || tc.Type().Kind() == reflect.Interface {
+				continue
+			}
+			pi.Conversions = append(pi.Conversions, fmt.Sprintf("%s -> %s", from, tc))
+		}
+	}
+	return pi
+}
+
+// MarshalJSON encodes the structured parts of Debugging: the
+// names of the included providers and the details of every provider
+// supplied.  Trace and Reproduce are not included since they are
+// free-form text.
+func (d Debugging) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		NamesIncluded []string       `json:"namesIncluded"`
+		Providers     []ProviderInfo `json:"providers"`
+	}{
+		NamesIncluded: d.NamesIncluded,
+		Providers:     d.Providers,
+	})
 }
 
-func formatFlow(flow []typeCode) string {
-	if !debugEnabled() {
+func (d *debugSink) formatFlow(flow []typeCode) string {
+	if d == nil {
 		return ""
 	}
 	var types []string
@@ -185,148 +279,270 @@ func formatFlow(flow []typeCode) string {
 }
 
 func generateReproduce(funcs []*provider, invokeF *provider, initF *provider) string {
-	subs := make(map[typeCode]string)
-	t := ""
+	r := newReproducer("")
 	f := "func TestRegression(t *testing.T) {\n"
 	f += "\twrapTest(t, func(t *testing.T) {\n"
 	f += "\t\tcalled := make(map[string]int)\n"
-	f += "\t\tvar invoker " + funcSig(subs, &t, reflect.TypeOf(invokeF.fn).Elem()) + "\n"
-	initName := "nil"
-	if initF != nil {
-		f += "\t\tvar initer " + funcSig(subs, &t, reflect.TypeOf(initF.fn).Elem()) + "\n"
-		initName = "&initer"
+	f += r.declareAndBind(funcs, invokeF, initF, "\t\t", true)
+	f += "\t\t\t).Bind(&invoker, " + r.initName(initF) + "))\n"
+	f += r.callInitInvoke(invokeF, initF, "\t\t")
+	f += "\t})\n"
+	f += "}\n"
+	return r.defineTypes + "\n" + f
+}
+
+// generateReproduceFile is like generateReproduce except that it
+// creates a complete and formatted _test.go file that can be put in
+// any package.  When checkCalled is true, the generated test asserts
+// that the included providers (and only those providers) were called.
+func generateReproduceFile(pkgName string, funcs []*provider, invokeF *provider, initF *provider, checkCalled bool) string {
+	r := newReproducer("nject.")
+	f := "func TestRegression(t *testing.T) {\n"
+	f += "\tcalled := make(map[string]int)\n"
+	f += r.declareAndBind(funcs, invokeF, initF, "\t", false)
+	f += "\t).Bind(&invoker, " + r.initName(initF) + ")\n"
+	f += "\tif err != nil {\n"
+	f += "\t\tt.Fatal(nject.DetailedError(err))\n"
+	f += "\t}\n"
+	f += r.callInitInvoke(invokeF, initF, "\t")
+	if checkCalled {
+		f += "\tfor _, expect := range []struct {\n"
+		f += "\t\tname   string\n"
+		f += "\t\tcalled bool\n"
+		f += "\t}{\n"
+		for _, fm := range r.named(funcs) {
+			if reflect.TypeOf(fm.fn).Kind() == reflect.Func {
+				f += fmt.Sprintf("\t\t{%q, %v},\n", reproduceName(fm), fm.include)
+			}
+		}
+		f += "\t} {\n"
+		f += "\t\tif expect.called && called[expect.name] == 0 {\n"
+		f += "\t\t\tt.Errorf(\"%s was not called\", expect.name)\n"
+		f += "\t\t}\n"
+		f += "\t\tif !expect.called && called[expect.name] != 0 {\n"
+		f += "\t\t\tt.Errorf(\"%s was called\", expect.name)\n"
+		f += "\t\t}\n"
+		f += "\t}\n"
+	} else {
+		f += "\t_ = called\n"
+	}
+	f += "}\n"
+
+	src := "package " + pkgName + "\n\n" +
+		"import (\n" +
+		"\t\"testing\"\n\n" +
+		"\t\"" + njectPkgPath + "\"\n" +
+		")\n\n" +
+		r.defineTypes + "\n" + f
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return src
+	}
+	return string(formatted)
+}
+
+var njectPkgPath = reflect.TypeOf(Debugging{}).PkgPath()
+
+type reproducer struct {
+	subs        map[typeCode]string
+	defineTypes string
+	qualifier   string // prefix for exported nject types, "" inside package nject
+}
+
+func newReproducer(qualifier string) *reproducer {
+	return &reproducer{
+		subs:      make(map[typeCode]string),
+		qualifier: qualifier,
+	}
+}
+
+func reproduceName(fm *provider) string {
+	if fm.index != -1 {
+		return fmt.Sprintf("%s-%d", fm.origin, fm.index)
 	}
-	f += "\t\trequire.NoError(t,\n"
-	f += "\t\t\tSequence(\"regression\",\n"
+	return fm.origin
+}
 
+// named returns the providers that will be in the reproduction
+func (r *reproducer) named(funcs []*provider) []*provider {
+	n := make([]*provider, 0, len(funcs))
 	for _, fm := range funcs {
-		if fm == nil {
-			continue
-		}
-		if fm.isSynthetic {
+		if fm == nil || fm.isSynthetic || fm.fn == nil {
 			continue
 		}
-		if fm.fn == nil {
-			continue
-		}
-		// f += fmt.Sprintf("\t\t\t\t// %s\n", fm)
-		f += "\t\t\t\t"
+		n = append(n, fm)
+	}
+	return n
+}
+
+func (r *reproducer) initName(initF *provider) string {
+	if initF != nil {
+		return "&initer"
+	}
+	return "nil"
+}
+
+// declareAndBind generates declarations for invoker and initer and then
+// the start of a Sequence() with all of the providers.  The caller must
+// close the Sequence.
+func (r *reproducer) declareAndBind(funcs []*provider, invokeF *provider, initF *provider, indent string, annotateIncluded bool) string {
+	f := indent + "var invoker " + r.funcSig(reflect.TypeOf(invokeF.fn).Elem()) + "\n"
+	if initF != nil {
+		f += indent + "var initer " + r.funcSig(reflect.TypeOf(initF.fn).Elem()) + "\n"
+	}
+	if r.qualifier == "" {
+		f += indent + "require.NoError(t,\n"
+		indent += "\t"
+	} else {
+		f += indent + "err := "
+	}
+	f += indent + r.qualifier + "Sequence(\"regression\",\n"
+
+	for _, fm := range r.named(funcs) {
+		f += indent + "\t"
 		close := ""
-		for annotation, active := range map[string]bool{
-			"Cacheable":           fm.cacheable,
-			"Required":            fm.required,
-			"Desired":             fm.desired,
-			"Memoize":             fm.memoize,
-			"Loose":               fm.loose,
-			"NotCacheable":        fm.notCacheable,
-			"MustConsume":         fm.mustConsume,
-			"ConsumptionOptional": fm.consumptionOptional,
+		for _, annotation := range []struct {
+			name   string
+			active bool
+		}{
+			{"Cacheable", fm.cacheable},
+			{"Required", fm.required},
+			{"Desired", fm.desired},
+			{"Memoize", fm.memoize},
+			{"Loose", fm.loose},
+			{"NotCacheable", fm.notCacheable},
+			{"MustConsume", fm.mustConsume},
+			{"ConsumptionOptional", fm.consumptionOptional},
+			{"Overrides", fm.overrides},
+			{"OptionalInputs", fm.optionalInputs},
+			{"Default", fm.isDefault},
+			{"AutoConvert", fm.autoConvert},
+			{"AdaptPointers", fm.adaptPointers},
 		} {
-			if active {
-				f += annotation + "("
+			if annotation.active {
+				f += r.qualifier + annotation.name + "("
 				close += ")"
 			}
 		}
-		n := fm.origin
-		if fm.index != -1 {
-			n = fmt.Sprintf("%s-%d", fm.origin, fm.index)
-		}
-		f += fmt.Sprintf("Provide(%q, ", n)
+		n := reproduceName(fm)
+		f += fmt.Sprintf("%sProvide(%q, ", r.qualifier, n)
 		close += ")"
 		typ := reflect.TypeOf(fm.fn)
 		if typ.Kind() == reflect.Func {
 			f += "func("
 			skip := 0
 			if fm.class == wrapperFunc {
-				f += "inner " + funcSig(subs, &t, typ.In(0)) + ", "
+				f += "inner " + r.funcSig(typ.In(0)) + ", "
 				skip = 1
 			}
-			f += strings.Join(addVarnames(substituteTypes(subs, &t, typesIn(typ)[skip:])), ", ") + ") "
+			f += strings.Join(addVarnames(r.substituteTypes(typesIn(typ)[skip:])), ", ") + ") "
 			out := typesOut(typ)
 			switch len(out) {
 			case 0:
 				// nothing
 			case 1:
-				f += " " + substituteTypes(subs, &t, out)[0]
+				f += " " + r.substituteTypes(out)[0]
 			default:
-				f += " (" + strings.Join(substituteTypes(subs, &t, out), ", ") + ")"
+				f += " (" + strings.Join(r.substituteTypes(out), ", ") + ")"
 			}
 			if fm.class == wrapperFunc {
 				f += " {\n"
-				f += fmt.Sprintf("\t\t\t\t\tcalled[%q]++\n", n)
-				f += "\t\t\t\t\tinner(" + strings.Join(substituteDefaults(subs, typesIn(typ.In(0))), ", ") + ")\n"
-				f += "\t\t\t\t\treturn " + strings.Join(substituteDefaults(subs, out), ", ") + "\n"
-				f += "\t\t\t\t}"
+				f += fmt.Sprintf("%s\t\tcalled[%q]++\n", indent, n)
+				f += indent + "\t\tinner(" + strings.Join(r.substituteDefaults(typesIn(typ.In(0))), ", ") + ")\n"
+				f += indent + "\t\treturn " + strings.Join(r.substituteDefaults(out), ", ") + "\n"
+				f += indent + "\t}"
 			} else {
-				f += fmt.Sprintf(" { called[%q]++; return %s }", n, strings.Join(substituteDefaults(subs, out), ", "))
+				f += fmt.Sprintf(" { called[%q]++; return %s }", n, strings.Join(r.substituteDefaults(out), ", "))
 			}
 			f += close + ","
-			if fm.include {
+			if fm.include && annotateIncluded {
 				f += " // included"
 			}
 			f += "\n"
 		} else {
-			tca := substituteTypes(subs, &t, []reflect.Type{typ})
-			def := substituteDefaults(subs, []reflect.Type{typ})
+			tca := r.substituteTypes([]reflect.Type{typ})
+			def := r.substituteDefaults([]reflect.Type{typ})
 			f += fmt.Sprintf("%s(%s)%s,\n", tca[0], def[0], close)
 		}
 	}
-	f += "\t\t\t).Bind(&invoker, " + initName + "))\n"
+	return f
+}
+
+func (r *reproducer) callInitInvoke(invokeF *provider, initF *provider, indent string) string {
+	var f string
 	if initF != nil {
-		f += "\t\tiniter(" + strings.Join(substituteDefaults(subs, typesIn(reflect.TypeOf(initF.fn).Elem())), ", ") + ")\n"
+		f += indent + "initer(" + strings.Join(r.substituteDefaults(typesIn(reflect.TypeOf(initF.fn).Elem())), ", ") + ")\n"
 	}
-	f += "\t\tinvoker(" + strings.Join(substituteDefaults(subs, typesIn(reflect.TypeOf(invokeF.fn).Elem())), ", ") + ")\n"
-	f += "\t})\n"
-	f += "}\n"
-	return t + "\n" + f
+	f += indent + "invoker(" + strings.Join(r.substituteDefaults(typesIn(reflect.TypeOf(invokeF.fn).Elem())), ", ") + ")\n"
+	return f
+}
+
+// exportedNjectType returns the name of the type, without package qualification,
+// if it is an exported type (or pointer to an exported type) from this package.
+func exportedNjectType(typ reflect.Type) (string, bool) {
+	prefix := ""
+	if typ.Kind() == reflect.Ptr {
+		prefix = "*"
+		typ = typ.Elem()
+	}
+	if typ.PkgPath() != njectPkgPath || !ast.IsExported(typ.Name()) {
+		return "", false
+	}
+	return prefix + typ.Name(), true
 }
 
 // TODO: take note of which interfaces implement each other and new interfaces that
 // follow the same pattern.
-func substituteTypes(subs map[typeCode]string, defineTypes *string, types []reflect.Type) []string {
+func (r *reproducer) substituteTypes(types []reflect.Type) []string {
 	var replacements []string
 	for _, typ := range types {
 		tc := getTypeCode(typ)
-		if subs[tc] == "" {
-			if strings.HasPrefix(typ.String(), "nject.") {
-				subs[tc] = strings.TrimPrefix(typ.String(), "nject.")
-			} else if strings.HasPrefix(typ.String(), "*nject.") {
-				subs[tc] = "*" + strings.TrimPrefix(typ.String(), "*nject.")
+		if r.subs[tc] == "" {
+			if name, ok := exportedNjectType(typ); ok && r.qualifier != "" {
+				if strings.HasPrefix(name, "*") {
+					r.subs[tc] = "*" + r.qualifier + name[1:]
+				} else {
+					r.subs[tc] = r.qualifier + name
+				}
+			} else if strings.HasPrefix(typ.String(), "nject.") && r.qualifier == "" {
+				r.subs[tc] = strings.TrimPrefix(typ.String(), "nject.")
+			} else if strings.HasPrefix(typ.String(), "*nject.") && r.qualifier == "" {
+				r.subs[tc] = "*" + strings.TrimPrefix(typ.String(), "*nject.")
 			} else if tc == getTypeCode(errorType) {
-				subs[tc] = "error"
+				r.subs[tc] = "error"
 			} else if typ.Kind() == reflect.Interface {
 				if typ.NumMethod() == 0 {
 					if typ.Name() == "interface {}" {
-						subs[tc] = "interface {}"
+						r.subs[tc] = "interface {}"
 					} else {
-						subs[tc] = fmt.Sprintf("i%03d", tc)
-						*defineTypes += fmt.Sprintf("type i%03d interface{} // %s\n", tc, tc)
+						r.subs[tc] = fmt.Sprintf("i%03d", tc)
+						r.defineTypes += fmt.Sprintf("type i%03d interface{} // %s\n", tc, tc)
 					}
 				} else {
-					subs[tc] = fmt.Sprintf("i%03d", tc)
-					*defineTypes += fmt.Sprintf("// %s\ntype i%03d interface{\n\tx%03d()\n}\n", tc, tc, tc)
+					r.subs[tc] = fmt.Sprintf("i%03d", tc)
+					r.defineTypes += fmt.Sprintf("// %s\ntype i%03d interface{\n\tx%03d()\n}\n", tc, tc, tc)
 				}
 			} else {
-				subs[tc] = fmt.Sprintf("s%03d", tc)
-				*defineTypes += fmt.Sprintf("// %s\ntype s%03d int\n", tc, tc)
+				r.subs[tc] = fmt.Sprintf("s%03d", tc)
+				r.defineTypes += fmt.Sprintf("// %s\ntype s%03d int\n", tc, tc)
 			}
 		}
-		replacements = append(replacements, subs[tc])
+		replacements = append(replacements, r.subs[tc])
 	}
 	return replacements
 }
 
-func substituteDefaults(subs map[typeCode]string, types []reflect.Type) []string {
+func (r *reproducer) substituteDefaults(types []reflect.Type) []string {
 	var def []string
 	for _, typ := range types {
-		r := subs[getTypeCode(typ)]
-		if strings.HasPrefix(r, "i") {
+		s := strings.TrimPrefix(r.subs[getTypeCode(typ)], r.qualifier)
+		if strings.HasPrefix(s, "i") {
 			def = append(def, "nil")
-		} else if strings.HasPrefix(r, "s") {
+		} else if strings.HasPrefix(s, "s") {
 			def = append(def, "0")
-		} else if r == "InjectorsDebugging" {
+		} else if s == "InjectorsDebugging" {
 			def = append(def, `""`)
-		} else if r == "InjectorsReproduce" {
+		} else if s == "InjectorsReproduce" {
 			def = append(def, `""`)
 		} else {
 			def = append(def, "nil")
@@ -335,18 +551,18 @@ func substituteDefaults(subs map[typeCode]string, types []reflect.Type) []string
 	return def
 }
 
-func funcSig(subs map[typeCode]string, defineTypes *string, typ reflect.Type) string {
+func (r *reproducer) funcSig(typ reflect.Type) string {
 	f := "func("
-	f += strings.Join(substituteTypes(subs, defineTypes, typesIn(typ)), ", ")
+	f += strings.Join(r.substituteTypes(typesIn(typ)), ", ")
 	f += ") "
 	out := typesOut(typ)
 	switch len(out) {
 	case 0:
 		// nothing
 	case 1:
-		f += " " + substituteTypes(subs, defineTypes, out)[0]
+		f += " " + r.substituteTypes(out)[0]
 	default:
-		f += " (" + strings.Join(substituteTypes(subs, defineTypes, out), ", ") + ")"
+		f += " (" + strings.Join(r.substituteTypes(out), ", ") + ")"
 	}
 	return f
 }
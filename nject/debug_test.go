@@ -1,28 +1,29 @@
 package nject
 
 import (
-	"sync/atomic"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type testLogWriter struct {
+	t *testing.T
+}
+
+func (w testLogWriter) Write(b []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(b), "\n"))
+	return len(b), nil
+}
+
 func debugOn(t *testing.T) {
-	debugOutputMu.Lock()
-	debuglnHook = func(stuff ...interface{}) {
-		t.Log(stuff...)
-	}
-	debugfHook = func(format string, stuff ...interface{}) {
-		t.Logf(format+"\n", stuff...)
-	}
-	debugOutputMu.Unlock()
-	atomic.StoreUint32(&debug, 1)
+	globalDebug.Store(newDebugSink(testLogWriter{t: t}))
 }
 
 func debugOff() {
-	debugOutputMu.Lock()
-	debuglnHook = nil
-	debugfHook = nil
-	debugOutputMu.Unlock()
-	atomic.StoreUint32(&debug, 0)
+	globalDebug.Store((*debugSink)(nil))
 }
 
 func wrapTest(t *testing.T, inner func(*testing.T)) {
@@ -34,3 +35,42 @@ func wrapTest(t *testing.T, inner func(*testing.T)) {
 		})
 	}
 }
+
+func TestDebugTo(t *testing.T) {
+	var trace strings.Builder
+	var invoke func(s1) s2
+	require.NoError(t, Sequence("DT",
+		func(s s1) s2 { return s2(s) },
+	).Bind(&invoke, nil, DebugTo(&trace)))
+	assert.Contains(t, trace.String(), "BEGIN characterizeAndFlatten")
+	before := trace.Len()
+	assert.Equal(t, s2("x"), invoke("x"))
+	assert.True(t, trace.Len() > before, "invoke is traced")
+}
+
+func TestConcurrentBindErrors(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var invoke func()
+			err := Sequence("broken", func(s9) s2 { return "" }, func(s2) {}).Bind(&invoke, nil)
+			if assert.Error(t, err) {
+				assert.Contains(t, DetailedError(err), "BEGIN characterizeAndFlatten")
+				assert.NotContains(t, DetailedError(err), "already capturing")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			var trace strings.Builder
+			var invoke func(s1) s2
+			assert.NoError(t, Sequence("works",
+				func(s s1) s2 { return s2(s) },
+			).Bind(&invoke, nil, DebugTo(&trace)))
+			assert.Equal(t, s2("y"), invoke("y"))
+			assert.NotContains(t, trace.String(), "broken")
+		}()
+	}
+	wg.Wait()
+}
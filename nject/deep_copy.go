@@ -0,0 +1,115 @@
+package nject
+
+// This file handles deep copies of values in a valueCollection.  When a
+// wrapper calls inner() more than once, each call gets its own copy of
+// the values that were passed down to the wrapper.
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DeepCopier can be implemented by types that need a fresh copy each
+// time a wrapper calls its inner() function.  Without a deep copy, a
+// wrapper that calls inner() more than once (for retries or fan-out)
+// hands the same pointer values to each call so mutations made by
+// downstream providers leak from one call to the next.
+//
+// DeepCopy must return a value of the same type as its receiver.
+type DeepCopier interface {
+	DeepCopy() interface{}
+}
+
+var deepCopierType = reflect.TypeOf((*DeepCopier)(nil)).Elem()
+
+type copierFunc func(reflect.Value) reflect.Value
+
+var copyFuncs = make(map[typeCode]copierFunc)
+var copyFuncsLock sync.RWMutex
+
+// RegisterDeepCopy registers a function that makes deep copies of
+// a type.  The function must have the shape func(T) T.  This can be used
+// for types that cannot implement DeepCopier, like types from other packages.
+// Registered functions take precedence over DeepCopier.
+//
+// Registration is global and should be done before calling Bind.
+// RegisterDeepCopy panics if copyFunc does not have the right shape.
+func RegisterDeepCopy(copyFunc interface{}) {
+	fv := reflect.ValueOf(copyFunc)
+	t := fv.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || t.In(0) != t.Out(0) {
+		panic(fmt.Sprintf("RegisterDeepCopy requires a func(T) T, not %s", t))
+	}
+	copyFuncsLock.Lock()
+	defer copyFuncsLock.Unlock()
+	copyFuncs[getTypeCode(t.In(0))] = func(v reflect.Value) reflect.Value {
+		return fv.Call([]reflect.Value{v})[0]
+	}
+}
+
+// deepCopierFor returns nil if the type does not need to be deep copied.
+// Types that are private to a module are registered by their public code.
+func deepCopierFor(tc typeCode) copierFunc {
+	_, tc = tc.scope()
+	copyFuncsLock.RLock()
+	f, found := copyFuncs[tc]
+	copyFuncsLock.RUnlock()
+	if found {
+		return f
+	}
+	t := tc.Type()
+	if !t.Implements(deepCopierType) {
+		return nil
+	}
+	return func(v reflect.Value) reflect.Value {
+		if !v.IsValid() || !v.CanInterface() {
+			return v
+		}
+		if isNil(v) {
+			return v
+		}
+		dup := reflect.ValueOf(v.Interface().(DeepCopier).DeepCopy())
+		if !dup.IsValid() {
+			return reflect.Zero(t)
+		}
+		if dup.Type() != t {
+			return dup.Convert(t)
+		}
+		return dup
+	}
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// deepCopyPlan records which positions in a valueCollection need to be
+// deep copied.
+type deepCopyPlan map[int]copierFunc
+
+func makeDeepCopyPlan(vMap map[typeCode]int) deepCopyPlan {
+	plan := make(deepCopyPlan)
+	for tc, i := range vMap {
+		if i < 0 {
+			continue
+		}
+		if f := deepCopierFor(tc); f != nil {
+			plan[i] = f
+		}
+	}
+	return plan
+}
+
+// apply deep copies, in place, the values in v that need deep copying.
+func (plan deepCopyPlan) apply(v valueCollection) {
+	for i, f := range plan {
+		if v[i].IsValid() {
+			v[i] = f(v[i])
+		}
+	}
+}
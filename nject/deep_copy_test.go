@@ -0,0 +1,137 @@
+package nject
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dcCounter struct {
+	n int
+}
+
+func (c *dcCounter) DeepCopy() interface{} {
+	dup := *c
+	return &dup
+}
+
+type dcRegistered struct {
+	n int
+}
+
+type dcShallow struct {
+	n int
+}
+
+func TestDeepCopyOnRepeatedInner(t *testing.T) {
+	RegisterDeepCopy(func(r *dcRegistered) *dcRegistered {
+		dup := *r
+		return &dup
+	})
+	wrapTest(t, func(t *testing.T) {
+		var seen [][3]int
+		var invoke func()
+		require.NoError(t, Sequence("DC",
+			func() (*dcCounter, *dcRegistered, *dcShallow) {
+				return &dcCounter{}, &dcRegistered{}, &dcShallow{}
+			},
+			func(inner func()) {
+				inner()
+				inner()
+				inner()
+			},
+			func(c *dcCounter, r *dcRegistered, s *dcShallow) {
+				c.n++
+				r.n++
+				s.n++
+				seen = append(seen, [3]int{c.n, r.n, s.n})
+			},
+		).Bind(&invoke, nil))
+		invoke()
+		assert.Equal(t, [][3]int{{1, 1, 1}, {1, 1, 2}, {1, 1, 3}}, seen)
+	})
+}
+
+func TestRegisterDeepCopyBadShape(t *testing.T) {
+	assert.Panics(t, func() { RegisterDeepCopy(func(int) string { return "" }) })
+	assert.Panics(t, func() { RegisterDeepCopy(7) })
+}
+
+type dcCounted struct {
+	copies *int
+}
+
+func (c *dcCounted) DeepCopy() interface{} {
+	*c.copies++
+	return &dcCounted{copies: c.copies}
+}
+
+func TestDeepCopyOnlyWhenInnerCalled(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var copies int
+		var invoke func(bool)
+		require.NoError(t, Sequence("count copies",
+			func() *dcCounted { return &dcCounted{copies: &copies} },
+			func(inner func(), call bool) {
+				if call {
+					inner()
+				}
+			},
+			func(c *dcCounted) {},
+		).Bind(&invoke, nil))
+		invoke(false)
+		assert.Equal(t, 0, copies, "inner not called")
+		invoke(true)
+		assert.Equal(t, 1, copies, "inner called once")
+	})
+}
+
+func TestDeepCopyBeforeFirstInner(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var seen []int
+		var invoke func() int
+		require.NoError(t, Sequence("first attempt",
+			func() *dcCounter { return &dcCounter{n: 10} },
+			func(inner func(), c *dcCounter) int {
+				inner()
+				inner()
+				return c.n
+			},
+			func(c *dcCounter) {
+				seen = append(seen, c.n)
+				c.n++
+			},
+		).Bind(&invoke, nil))
+		assert.Equal(t, 10, invoke(), "the wrapper's value is not changed")
+		assert.Equal(t, []int{10, 10}, seen, "the second attempt sees the clean value")
+	})
+}
+
+func TestDeepCopyModulePrivate(t *testing.T) {
+	RegisterDeepCopy(func(r *dcRegistered) *dcRegistered {
+		dup := *r
+		return &dup
+	})
+	wrapTest(t, func(t *testing.T) {
+		var seen [][2]int
+		var invoke func()
+		require.NoError(t, Module("private copies", []reflect.Type{},
+			func() (*dcCounter, *dcRegistered) {
+				return &dcCounter{}, &dcRegistered{}
+			},
+			func(inner func()) {
+				inner()
+				inner()
+			},
+			func(c *dcCounter, r *dcRegistered) {
+				c.n++
+				r.n++
+				seen = append(seen, [2]int{c.n, r.n})
+			},
+		).Bind(&invoke, nil))
+		invoke()
+		assert.Equal(t, [][2]int{{1, 1}, {1, 1}}, seen)
+	})
+}
@@ -0,0 +1,149 @@
+package nject
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called []string
+		library := Sequence("library",
+			Provide("default-s1", Default(func() s1 {
+				called = append(called, "default-s1")
+				return "default"
+			})),
+			Provide("default-s2", Default(s2("default"))),
+		)
+		final := func(a s1, b s2) string { return string(a) + " " + string(b) }
+
+		cases := []struct {
+			name  string
+			chain *Collection
+			want  string
+			calls []string
+		}{
+			{
+				name:  "defaults only",
+				chain: Sequence("defaults", library, final),
+				want:  "default default",
+				calls: []string{"default-s1"},
+			},
+			{
+				name:  "override before",
+				chain: Sequence("before", s2("app"), func() s1 { return "app" }, library, final),
+				want:  "app app",
+			},
+			{
+				name:  "override after",
+				chain: Sequence("after", library, s2("app"), func() s1 { return "app" }, final),
+				want:  "app app",
+			},
+		}
+		for _, tc := range cases {
+			called = nil
+			var invoke func() string
+			require.NoError(t, tc.chain.Bind(&invoke, nil), tc.name)
+			assert.Equal(t, tc.want, invoke(), tc.name)
+			assert.Equal(t, tc.calls, called, tc.name)
+		}
+	})
+}
+
+func TestDefaultStillRunsForOtherOutputs(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() (s1, s3)
+		require.NoError(t, Sequence("both",
+			func() s1 { return "app" },
+			Default(func() (s1, s3) { return "default", "default" }),
+			func(a s1, c s3) (s1, s3) { return a, c },
+		).Bind(&invoke, nil))
+		a, c := invoke()
+		assert.Equal(t, s1("app"), a)
+		assert.Equal(t, s3("default"), c)
+	})
+}
+
+func TestDefaultFor(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() (s1, s3)
+		require.NoError(t, Sequence("some",
+			func() s1 { return "app" },
+			DefaultFor([]reflect.Type{reflect.TypeOf(s1(""))}, Sequence("library",
+				func() (s1, s3) { return "library", "library" },
+			)),
+			func(a s1, c s3) (s1, s3) { return a, c },
+		).Bind(&invoke, nil))
+		a, c := invoke()
+		assert.Equal(t, s1("app"), a)
+		assert.Equal(t, s3("library"), c)
+	})
+}
+
+func TestDefaultForPosition(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		library := DefaultFor([]reflect.Type{reflect.TypeOf(s1(""))}, Sequence("library",
+			func() (s1, s3) { return "library", "library" },
+		))
+		final := func(a s1, c s3) (s1, s3) { return a, c }
+		cases := []struct {
+			name  string
+			chain *Collection
+			s1    s1
+			s3    s3
+		}{
+			{
+				name:  "default only",
+				chain: Sequence("only", library, final),
+				s1:    "library",
+				s3:    "library",
+			},
+			{
+				name:  "app after",
+				chain: Sequence("after", library, func() s1 { return "app" }, final),
+				s1:    "app",
+				s3:    "library",
+			},
+			{
+				// s3 is not a default so the normal closest-wins rule applies
+				name:  "other type",
+				chain: Sequence("other", func() s3 { return "app" }, library, final),
+				s1:    "library",
+				s3:    "library",
+			},
+			{
+				name:  "other type after",
+				chain: Sequence("other after", library, func() s3 { return "app" }, final),
+				s1:    "library",
+				s3:    "app",
+			},
+		}
+		for _, tc := range cases {
+			var invoke func() (s1, s3)
+			require.NoError(t, tc.chain.Bind(&invoke, nil), tc.name)
+			a, c := invoke()
+			assert.Equal(t, tc.s1, a, tc.name)
+			assert.Equal(t, tc.s3, c, tc.name)
+		}
+	})
+}
+
+func TestDefaultForAccumulates(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		library := DefaultFor([]reflect.Type{reflect.TypeOf(s3(""))},
+			DefaultFor([]reflect.Type{reflect.TypeOf(s1(""))},
+				func() (s1, s3) { return "library", "library" }))
+		var invoke func() (s1, s3)
+		require.NoError(t, Sequence("both",
+			func() (s1, s3) { return "app", "app" },
+			library,
+			func(a s1, c s3) (s1, s3) { return a, c },
+		).Bind(&invoke, nil, Strict()))
+		a, c := invoke()
+		assert.Equal(t, s1("app"), a)
+		assert.Equal(t, s3("app"), c)
+	})
+}
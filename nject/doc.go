@@ -69,6 +69,13 @@ The STATIC set is composed of the cacheable injectors.
 
 The RUN set if everything else.
 
+Collections created with Module() keep their types private: only the types
+that the module exports can flow between the providers in the module and
+the providers outside of it.
+
+Collections can also be assembled from a JSON description of providers
+that have been registered by name with Register().  See Load().
+
 Injectors
 
 All injectors have the following type signature:
@@ -111,6 +118,17 @@ Memoized injectors may not have any inputs that are go maps, slices, or function
 Arrays, structs, and interfaces are okay.  This requirement is recursive so a struct that
 that has a slice in it is not okay.
 
+Lazy injectors
+
+Injectors annotated with Lazy() provide a function instead of a value.  The
+function has a named type that takes no arguments and returns the outputs
+of the injector.  The injector only runs when the function is called so
+consumers that only sometimes need an expensive value do not always pay
+for it.  Injectors that exist only to provide the inputs of a Lazy injector
+are deferred along with it: they run, in chain order, when the function is
+first called.  Injectors whose outputs are used by anything else still run
+when the chain runs.
+
 Fallible injectors
 
 Fallible injectors are injectors that return a value of type TerminalError.
@@ -145,7 +163,18 @@ If there is an init function and it returns error, then the value returned
 by the fallible injector will be returned via init fuction.  Unlike
 fallible injectors in the RUN set, the error output by a fallible injector
 in the STATIC set is available downstream (but only in the RUN set -- nothing
-else in the STATIC set will execute).
+else in the STATIC set will execute).  If nothing consumes the error, that
+is if there is no init function, or it does not return error, and nothing
+in the RUN set takes error as an input, then the invoke function returns
+the error instead of running the RUN set.  If the invoke function does not
+return error, it panics.  Initialization only happens once unless Bind is given the
+RetryableInit option.
+
+Bindings made with the Reloader option can re-run the STATIC set, for
+example to pick up new credentials.  Invocations that are running when
+the reload happens finish with the old values.  Providers can consume
+*Generation to register hooks that run when their values are retired.
+Without Reloader, nothing is ever retired and those hooks are not called.
 
 Some examples:
 
@@ -189,11 +218,28 @@ providers.  The value(s) returned by inner come from the return
 values of other wrapper functions and from the return value(s) of
 the final function.
 
-Wrap functions can call inner() zero or more times.
+Wrap functions can call inner() zero or more times.  When inner() is
+called more than once, the values passed down to the wrapper are restored
+before each call.  Values whose type implements DeepCopier, or whose type
+has a copy function registered with RegisterDeepCopy, are deep copied so
+that changes made during one call to inner() are not seen by the next.
 
 The values returned by wrap functions must be consumed by another
 upstream wrap function or by the init function (if using Bind()).
 
+Retries
+
+Fallible injectors and wrap functions can be annotated with Retry().  A
+fallible injector is re-run when it returns an error.  For a wrap function,
+the rest of the chain is re-run when it returns an error.  A RetryPolicy
+controls the number of attempts, the backoff, and which errors are retried.
+
+Timeouts
+
+Injectors and wrap functions can be annotated with Timeout().  If they
+do not finish in time, the chain stops with a TimeoutError.  Providers
+that take a context.Context are given one with the deadline.
+
 Final functions
 
 Final functions are simply the last provider in the chain.
@@ -222,6 +268,17 @@ Bind() and Run() will return error rather than panic.  After Bind()ing
 an init and invoke function, calling them will not panic unless a provider
 panic()s
 
+BindAsync() is like Bind() except that the invoke function runs the chain
+in a goroutine and returns a function that waits for the results.  With
+BindAsync(), panics are captured and returned as PanicError.
+
+Tracing
+
+To see what happened during a single call to an invoke function, include
+*InvokeTrace as an input of the invoke function.  When a non-nil
+*InvokeTrace is passed, each provider in the RUN set records the values it
+provided and returned and the point where a fallible injector stopped the chain.
+
 Chain evaluation
 
 Bind() uses a complex and somewhat expensive O(n^2) set of rules to evaluate
@@ -245,10 +302,43 @@ Only include if all outputs are transitively consumed by a required
 or Desired chain element.
 
 When there are multiple providers of a type, Bind() tries to get it
-from the closest provider.
+from the closest provider.  Providers annotated with Default() are only
+used when there is no other provider of the type.  DefaultFor() does the
+same for only some of the types of a provider.
+
+DefaultFor() takes a list of types.  Those types are provided as if the
+provider were annotated with Default(): another provider of the type, before
+or after it, wins.  The provider's other outputs are provided normally so they
+still shadow earlier providers and are shadowed by later ones.  When applied to
+a Collection, it applies to every provider in the Collection:
+
+	nject.Sequence("app",
+		nject.DefaultFor([]reflect.Type{reflect.TypeOf(clock(nil))}, library),
+		realClock,
+		handler,
+	)
+
+Here the library's clock is only used if realClock is removed but the other
+types that the library provides are used as usual.  Strict() does not report
+providers annotated with DefaultFor() as unused or as shadowed for the listed
+types.
+
+Types must usually match exactly.  Providers annotated with
+ConvertibleTo() or AutoConvert() can also satisfy consumers of other
+types that their values can be converted to, eg from UserID to
+AccountOwnerID when both are strings.  Providers annotated with
+AdaptPointers() can satisfy consumers of types that differ by one pointer
+level, eg *Config for Config.  Conversions are only used when no
+provider has the exact type and are listed in Debugging.Providers.
 
 Providers that have unmet dependencies will be eliminated from the chain
-unless they're Required.
+unless they're Required.  Providers that are annotated with OptionalInputs()
+are given zero values for the inputs that cannot be met instead.
+
+Since providers are dropped silently, binding with the Strict() option
+can be used in tests to report providers that are not included and
+providers that shadow earlier providers of the same type.  Use Overrides()
+to mark a provider that is meant to replace earlier providers.
 
 */
 package nject
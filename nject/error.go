@@ -1,14 +1,19 @@
 package nject
 
 type njectError struct {
-	err     error
-	details string
+	err           error
+	details       string
+	reproduceFile func(pkgName string) string
 }
 
 func (ne *njectError) Error() string {
 	return ne.err.Error()
 }
 
+func (ne *njectError) Unwrap() error {
+	return ne.err
+}
+
 // DetailedError transforms errors into strings.  If
 // the error happens to be an error returned by Bind()
 // or something that called Bind() then it will return
@@ -19,3 +24,16 @@ func DetailedError(err error) string {
 	}
 	return err.Error()
 }
+
+// ReproduceBindError returns the source of a complete _test.go file, in
+// package pkgName, that attempts to reproduce a failed Bind().  The
+// types in the provider chain are anonymized.  The test in the file will
+// fail until the provider chain can be bound.  If the error was not
+// returned by Bind() or something that called Bind(), ReproduceBindError
+// returns the empty string.
+func ReproduceBindError(err error, pkgName string) string {
+	if njerr, ok := err.(*njectError); ok && njerr.reproduceFile != nil {
+		return njerr.reproduceFile(pkgName)
+	}
+	return ""
+}
@@ -0,0 +1,241 @@
+package nject
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explanation describes why a single provider was included in, or
+// excluded from, a provider chain.
+type Explanation struct {
+	// Provider is the provider being explained
+	Provider string
+	// Included is true if the provider is part of the chain
+	Included bool
+	// Reason is the same as the reason given in Debugging.IncludeExclude
+	Reason string
+	// Path is the chain of cause and effect.  For an included provider,
+	// it traces how the values it provides are consumed until reaching
+	// a provider that is required or desired.  For an excluded provider,
+	// it traces back through unmet dependencies or unconsumed outputs.
+	Path []string
+}
+
+// String formats the explanation as a short causal path
+func (e Explanation) String() string {
+	verb := "excluded"
+	if e.Included {
+		verb = "included"
+	}
+	s := fmt.Sprintf("%s is %s", e.Provider, verb)
+	if len(e.Path) == 0 {
+		return s + ": " + e.Reason
+	}
+	return s + ":\n\t" + strings.Join(e.Path, "\n\t")
+}
+
+// Explain figures out why the provider named providerName is included
+// or excluded when binding the Collection with invokeFunc and initFunc.
+// The arguments are the same as for Bind but invokeFunc and initFunc are
+// not modified.  The providerName can be the name given with Provide(),
+// the name from Debugging.NamesIncluded (eg "collection(3)"), or the
+// full path to the provider (eg "service/auth/loadUser").  It is an error
+// if more than one provider has that name.
+//
+// Explain works for chains where some providers cannot be included, but if
+// Bind would fail with the same options, Explain returns the error from Bind
+// instead of an Explanation.
+func (c *Collection) Explain(invokeFunc interface{}, initFunc interface{}, providerName string, opts ...BindOption) (Explanation, error) {
+	options := newBindOptions(opts)
+	invokeF := newProvider(invokeFunc, -1, c.name+" invoke func")
+	var initF *provider
+	if initFunc != nil {
+		initF = newProvider(initFunc, -1, c.name+" initialization func")
+	}
+	ch, err := c.buildChain(invokeF, initF, options.debug)
+	if err != nil {
+		return Explanation{}, err
+	}
+	err = computeDependenciesAndInclusion(ch.funcs, ch.initF, options.debug)
+	if err != nil {
+		return Explanation{}, err
+	}
+	err = checkStrict(ch.funcs, options)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	// The final flow calculation skips excluded providers so recompute
+	// the flows as if everything were included.  The inclusion decisions
+	// are kept.
+	cannotInclude := make([]error, len(ch.funcs))
+	for i, fm := range ch.funcs {
+		cannotInclude[i] = fm.cannotInclude
+		fm.cannotInclude = nil
+	}
+	err = providesReturns(ch.funcs, ch.initF, nil)
+	for i, fm := range ch.funcs {
+		fm.cannotInclude = cannotInclude[i]
+	}
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	var matches []*provider
+	for _, fm := range ch.funcs {
+		if fm.shortName() == providerName || fm.fullName() == providerName {
+			matches = append(matches, fm)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Explanation{}, fmt.Errorf("no provider named %q in %s", providerName, c.name)
+	case 1:
+	default:
+		names := make([]string, len(matches))
+		for i, fm := range matches {
+			names[i] = fm.fullName()
+		}
+		return Explanation{}, fmt.Errorf("more than one provider named %q in %s: %s", providerName, c.name, strings.Join(names, ", "))
+	}
+	found := matches[0]
+	e := Explanation{
+		Provider: found.shortName(),
+		Included: found.include,
+	}
+	if found.include {
+		e.Reason = found.whyIncluded
+		e.Path = explainInclusion(found)
+	} else {
+		if found.cannotInclude != nil {
+			e.Reason = found.cannotInclude.Error()
+		}
+		e.Path = explainExclusion(found, make(map[*provider]bool))
+	}
+	return e, nil
+}
+
+// rootReason returns "" if fm is not included for its own sake
+func rootReason(fm *provider) string {
+	switch {
+	case fm.required:
+		return "required"
+	case fm.desired:
+		return "desired"
+	case fm.wanted:
+		return "wanted because it has no outputs"
+	}
+	return ""
+}
+
+// usesVia returns how the consumer gets a value from the source.
+func usesVia(consumer *provider, source *provider) string {
+	for _, param := range []flowType{inputParams, bypassParams, returnedParams} {
+		for tc, plist := range consumer.d.usesDetail[param] {
+			for _, p := range plist {
+				if p == source {
+					if param == returnedParams {
+						return fmt.Sprintf("returns %s to", tc)
+					}
+					return fmt.Sprintf("provides %s to", tc)
+				}
+			}
+		}
+	}
+	return "is needed by"
+}
+
+// explainInclusion does a breadth-first search through the consumers of
+// fm to find the shortest path to a provider that is included for its
+// own sake.
+func explainInclusion(fm *provider) []string {
+	if why := rootReason(fm); why != "" {
+		return []string{fmt.Sprintf("%s is %s", fm.shortName(), why)}
+	}
+	from := map[*provider]*provider{fm: nil}
+	queue := []*provider{fm}
+	for len(queue) > 0 {
+		var p *provider
+		p, queue = queue[0], queue[1:]
+		for _, user := range p.d.usedBy {
+			if !user.include {
+				continue
+			}
+			if _, seen := from[user]; seen {
+				continue
+			}
+			from[user] = p
+			if why := rootReason(user); why != "" {
+				var path []string
+				path = append(path, fmt.Sprintf("%s is %s", user.shortName(), why))
+				for c := user; from[c] != nil; c = from[c] {
+					path = append(path, fmt.Sprintf("%s %s %s", from[c].shortName(), usesVia(c, from[c]), c.shortName()))
+				}
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			queue = append(queue, user)
+		}
+	}
+	return nil
+}
+
+// explainExclusion follows unmet dependencies and unconsumed outputs
+func explainExclusion(fm *provider, seen map[*provider]bool) []string {
+	if seen[fm] {
+		return nil
+	}
+	seen[fm] = true
+	for _, param := range []flowType{inputParams, bypassParams, returnedParams} {
+		for _, tc := range fm.flows[param] {
+			if _, missing := fm.d.usesError[param][tc]; missing {
+				return []string{fmt.Sprintf("%s has no provider for %s in %s", fm.shortName(), tc, param)}
+			}
+		}
+	}
+	for _, param := range []flowType{inputParams, bypassParams, returnedParams} {
+	Source:
+		for _, tc := range fm.flows[param] {
+			var blocked *provider
+			for _, p := range fm.d.usesDetail[param][tc] {
+				if p.include {
+					continue Source
+				}
+				blocked = p
+			}
+			if blocked == nil {
+				continue
+			}
+			reason := "it is excluded"
+			if blocked.cannotInclude != nil {
+				reason = blocked.cannotInclude.Error()
+			}
+			path := []string{fmt.Sprintf("%s needs %s in %s from %s which is excluded because %s", fm.shortName(), tc, param, blocked.shortName(), reason)}
+			return append(path, explainExclusion(blocked, seen)...)
+		}
+	}
+	for _, param := range []flowType{outputParams, returnParams} {
+		if !fm.d.mustConsumeFlow[param] {
+			continue
+		}
+	Param:
+		for _, tc := range fm.flows[param] {
+			for _, p := range fm.d.usedByDetail[param][tc] {
+				if p.include {
+					continue Param
+				}
+			}
+			return []string{fmt.Sprintf("%s must have its %s %s consumed but nothing included consumes it", fm.shortName(), param, tc)}
+		}
+	}
+	var consumers []string
+	for _, user := range fm.d.usedBy {
+		consumers = append(consumers, user.shortName())
+	}
+	if len(consumers) == 0 {
+		return []string{fmt.Sprintf("nothing consumes the outputs of %s", fm.shortName())}
+	}
+	return []string{fmt.Sprintf("%s is only used by providers that are not included: %s", fm.shortName(), strings.Join(consumers, ", "))}
+}
@@ -0,0 +1,109 @@
+package nject
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		c := Sequence("explain",
+			Provide("S0", func() s0 { return "" }),
+			Provide("S1", func(s0) s1 { return "" }),
+			Provide("S2", func(s1) s2 { return "" }),
+			Provide("S4", func(s3) s4 { return "" }),
+			Provide("S5", func(s4) {}),
+			Provide("S6", MustConsume(func() s6 { return "" })),
+			func(s2) s7 { return "" },
+			Provide("FINAL", func(s2) {}),
+		)
+		var invoke func()
+
+		e, err := c.Explain(&invoke, nil, "S0")
+		require.NoError(t, err)
+		assert.True(t, e.Included)
+		assert.Equal(t, []string{
+			"S0 provides nject.s0 to S1",
+			"S1 provides nject.s1 to S2",
+			"S2 provides nject.s2 to FINAL",
+			"FINAL is required",
+		}, e.Path)
+		assert.Equal(t, "S0 is included:\n\tS0 provides nject.s0 to S1\n\tS1 provides nject.s1 to S2\n\tS2 provides nject.s2 to FINAL\n\tFINAL is required", e.String())
+
+		e, err = c.Explain(&invoke, nil, "S5")
+		require.NoError(t, err)
+		assert.False(t, e.Included)
+		assert.Equal(t, []string{
+			"S5 needs nject.s4 in inputs from S4 which is excluded because has no match for its input parameter nject.s3",
+			"S4 has no provider for nject.s3 in inputs",
+		}, e.Path)
+
+		e, err = c.Explain(&invoke, nil, "S6")
+		require.NoError(t, err)
+		assert.False(t, e.Included)
+		assert.Equal(t, []string{"S6 must have its outputs nject.s6 consumed but nothing included consumes it"}, e.Path)
+
+		e, err = c.Explain(&invoke, nil, "explain(6)")
+		require.NoError(t, err)
+		assert.Equal(t, "explain(6)", e.Provider)
+		assert.False(t, e.Included)
+		assert.Equal(t, []string{"nothing consumes the outputs of explain(6)"}, e.Path)
+
+		_, err = c.Explain(&invoke, nil, "S99")
+		assert.Error(t, err)
+
+		_, err = c.Explain(&invoke, nil, "explain")
+		assert.Error(t, err, "the collection name alone does not name a provider")
+
+		dup := Sequence("dup",
+			Provide("S0", func() s0 { return "" }),
+			Provide("S0", func(s0) s1 { return "" }),
+			Provide("FINAL", func(s1) {}),
+		)
+		_, err = dup.Explain(&invoke, nil, "S0")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "more than one provider")
+		}
+		e, err = dup.Explain(&invoke, nil, "FINAL")
+		require.NoError(t, err)
+		assert.True(t, e.Included)
+		assert.Nil(t, invoke, "invoke is not bound")
+	})
+}
+
+func TestExplainBindErrors(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func()
+
+		c := Sequence("unbindable",
+			Provide("S0", func() s0 { return "" }),
+			Provide("NEEDS", Required(func(s1) {})),
+		)
+		_, err := c.Explain(&invoke, nil, "S0")
+		assert.Error(t, err, "Bind would fail")
+		assert.Error(t, c.Bind(&invoke, nil))
+
+		c = Sequence("strict",
+			Provide("S0", func() s0 { return "" }),
+			Provide("UNUSED", func() s1 { return "" }),
+			Provide("FINAL", func(s0) {}),
+		)
+		e, err := c.Explain(&invoke, nil, "UNUSED")
+		require.NoError(t, err)
+		assert.False(t, e.Included)
+		_, err = c.Explain(&invoke, nil, "UNUSED", Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "is not included")
+		}
+
+		var buf bytes.Buffer
+		e, err = c.Explain(&invoke, nil, "S0", DebugTo(&buf))
+		require.NoError(t, err)
+		assert.True(t, e.Included)
+		assert.NotEmpty(t, buf.String(), "debug output")
+		assert.Nil(t, invoke, "invoke is not bound")
+	})
+}
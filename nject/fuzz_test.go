@@ -0,0 +1,442 @@
+//go:build go1.18
+// +build go1.18
+
+package nject
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Synthetic types for generated chains, in the style of generateReproduce
+type s000 int
+type s001 int
+type s002 int
+type s003 int
+type s004 int
+type s005 int
+
+type i000 interface {
+	x000()
+}
+
+type i001 interface {
+	x001()
+}
+
+func (s000) x000() {}
+func (s001) x000() {}
+func (s002) x001() {}
+func (s003) x001() {}
+
+var fuzzValueTypes = []reflect.Type{
+	reflect.TypeOf(s000(0)),
+	reflect.TypeOf(s001(0)),
+	reflect.TypeOf(s002(0)),
+	reflect.TypeOf(s003(0)),
+	reflect.TypeOf(s004(0)),
+	reflect.TypeOf(s005(0)),
+}
+
+var fuzzInputTypes = append([]reflect.Type{
+	reflect.TypeOf((*i000)(nil)).Elem(),
+	reflect.TypeOf((*i001)(nil)).Elem(),
+}, fuzzValueTypes...)
+
+// fuzzBytes doles out the fuzz input.  It returns zeros when
+// the input is used up.
+type fuzzBytes []byte
+
+func (b *fuzzBytes) next(n int) int {
+	if len(*b) == 0 {
+		return 0
+	}
+	v := int((*b)[0])
+	*b = (*b)[1:]
+	return v % n
+}
+
+func (b *fuzzBytes) bit() bool { return b.next(2) == 1 }
+
+func (b *fuzzBytes) types(choices []reflect.Type, max int) []reflect.Type {
+	n := b.next(max + 1)
+	seen := make(map[reflect.Type]bool)
+	var types []reflect.Type
+	for i := 0; i < n; i++ {
+		t := choices[b.next(len(choices))]
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// fuzzInt turns a value from a generated chain into an int so
+// that the outputs of providers depend upon their inputs
+func fuzzInt(v reflect.Value) int64 {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int:
+		return v.Int()
+	case reflect.Ptr:
+		if v.IsNil() {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
+
+func fuzzAccumulate(index int, in []reflect.Value) int64 {
+	acc := int64(index + 1)
+	for _, v := range in {
+		acc = acc*31 + fuzzInt(v)
+	}
+	return acc
+}
+
+func fuzzOutputs(types []reflect.Type, acc int64) []reflect.Value {
+	out := make([]reflect.Value, len(types))
+	for i, t := range types {
+		out[i] = reflect.New(t).Elem()
+		switch {
+		case t == errorType:
+			if acc%5 == 0 {
+				out[i].Set(reflect.ValueOf(fmt.Errorf("failed %d", acc)))
+			}
+		case t == terminalErrorType:
+			if acc%5 == 0 {
+				out[i].Set(reflect.ValueOf(errors.New("terminal")))
+			}
+		default:
+			out[i].SetInt(acc + int64(i))
+		}
+	}
+	return out
+}
+
+// fuzzChain is a randomly generated collection and invoke type
+type fuzzChain struct {
+	collection *Collection
+	invokeType reflect.Type
+	invokeArgs []reflect.Value
+}
+
+func generateFuzzChain(b *fuzzBytes) fuzzChain {
+	returnChoices := [][]reflect.Type{
+		nil,
+		{reflect.TypeOf(s005(0))},
+		{reflect.TypeOf(s005(0)), errorType},
+	}
+	returns := returnChoices[b.next(len(returnChoices))]
+	invokeIn := b.types(fuzzValueTypes[:3], 2)
+	invokeArgs := make([]reflect.Value, len(invokeIn))
+	for i, t := range invokeIn {
+		invokeArgs[i] = reflect.New(t).Elem()
+		invokeArgs[i].SetInt(int64(b.next(100)))
+	}
+
+	// Inputs are usually picked from the types that are already
+	// available so that most chains can be bound.  Chains that
+	// cannot be bound do not test anything.
+	available := append([]reflect.Type(nil), invokeIn...)
+	inputs := func(max int) []reflect.Type {
+		if len(available) == 0 || b.next(4) == 0 {
+			return b.types(fuzzInputTypes, max)
+		}
+		return b.types(available, max)
+	}
+	canFail := len(returns) == 2
+
+	var providers []interface{}
+	count := 1 + b.next(8)
+	for index := 0; index < count; index++ {
+		var fn interface{}
+		var provided []reflect.Type
+		kind := b.next(4)
+		if kind == 2 && !canFail {
+			// a fallible injector needs an error to return
+			kind = 1
+		}
+		switch kind {
+		case 0: // literal
+			v := reflect.New(fuzzValueTypes[b.next(len(fuzzValueTypes))]).Elem()
+			v.SetInt(int64(index))
+			fn = v.Interface()
+			provided = []reflect.Type{v.Type()}
+		case 1: // injector
+			in := inputs(2)
+			out := b.types(fuzzValueTypes, 2)
+			provided = out
+			index := index
+			fn = reflect.MakeFunc(reflect.FuncOf(in, out, false), func(args []reflect.Value) []reflect.Value {
+				return fuzzOutputs(out, fuzzAccumulate(index, args))
+			}).Interface()
+		case 2: // fallible injector
+			in := inputs(2)
+			provided = b.types(fuzzValueTypes, 2)
+			out := append(append([]reflect.Type(nil), provided...), terminalErrorType)
+			index := index
+			fn = reflect.MakeFunc(reflect.FuncOf(in, out, false), func(args []reflect.Value) []reflect.Value {
+				return fuzzOutputs(out, fuzzAccumulate(index, args))
+			}).Interface()
+		case 3: // wrapper
+			in := inputs(2)
+			out := b.types(fuzzValueTypes, 2)
+			provided = out
+			var rets []reflect.Type
+			for _, t := range returns {
+				if b.bit() {
+					rets = append(rets, t)
+				}
+			}
+			inner := reflect.FuncOf(out, rets, false)
+			index := index
+			fn = reflect.MakeFunc(reflect.FuncOf(append([]reflect.Type{inner}, in...), rets, false), func(args []reflect.Value) []reflect.Value {
+				acc := fuzzAccumulate(index, args[1:])
+				if acc%7 == 0 {
+					return fuzzOutputs(rets, acc)
+				}
+				r := args[0].Call(fuzzOutputs(out, acc))
+				for i, v := range r {
+					if v.Kind() == reflect.Int {
+						r[i] = reflect.ValueOf(v.Int() + int64(index)).Convert(v.Type())
+					}
+				}
+				return r
+			}).Interface()
+		}
+		if b.bit() {
+			switch b.next(5) {
+			case 0:
+				fn = Cacheable(fn)
+			case 1:
+				fn = MustConsume(fn)
+			case 2:
+				fn = Desired(fn)
+			case 3:
+				fn = Loose(fn)
+			case 4:
+				fn = Required(fn)
+			}
+		}
+		providers = append(providers, fn)
+		available = append(available, provided...)
+	}
+
+	finalIn := inputs(3)
+	providers = append(providers, reflect.MakeFunc(reflect.FuncOf(finalIn, returns, false), func(args []reflect.Value) []reflect.Value {
+		return fuzzOutputs(returns, fuzzAccumulate(count, args))
+	}).Interface())
+
+	return fuzzChain{
+		collection: Sequence("fuzz", providers...),
+		invokeType: reflect.FuncOf(invokeIn, returns, false),
+		invokeArgs: invokeArgs,
+	}
+}
+
+func (fc fuzzChain) bind() (reflect.Value, error) {
+	invoke := reflect.New(fc.invokeType)
+	err := fc.collection.Bind(invoke.Interface(), nil)
+	return invoke.Elem(), err
+}
+
+func (fc fuzzChain) call(invoke reflect.Value) string {
+	return fmt.Sprint(valuesInterfaces(invoke.Call(fc.invokeArgs)))
+}
+
+func valuesInterfaces(values []reflect.Value) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v.Interface()
+	}
+	return out
+}
+
+// fuzzMatches is the reference model for matching: an exact type
+// match or, for a loose provider or a loose consumer of returned values,
+// a type that implements the interface.
+func fuzzMatches(want typeCode, have typeCode, loose bool) bool {
+	if want == have {
+		return true
+	}
+	return loose && want.Type().Kind() == reflect.Interface && have.Type().Implements(want.Type())
+}
+
+// checkFuzzInvariants checks the included providers against a
+// reference model that does not use the dependency data computed
+// by the binder.
+func checkFuzzInvariants(t *testing.T, funcs []*provider) {
+	for p, fm := range funcs {
+		if fm.required && !fm.include {
+			t.Errorf("required provider %s was not included", fm)
+		}
+		if !fm.include {
+			continue
+		}
+	Input:
+		for _, tc := range fm.flows[inputParams] {
+			if tc == noTypeCode {
+				continue
+			}
+			for _, source := range funcs[:p] {
+				if !source.include {
+					continue
+				}
+				for _, out := range source.flows[outputParams] {
+					if fuzzMatches(tc, out, source.loose) {
+						continue Input
+					}
+				}
+			}
+			t.Errorf("included provider %s has no source for input %s", fm, tc)
+		}
+	Returned:
+		for _, tc := range fm.flows[returnedParams] {
+			if tc == noTypeCode {
+				continue
+			}
+			for _, source := range funcs[p+1:] {
+				if !source.include {
+					continue
+				}
+				for _, ret := range source.flows[returnParams] {
+					if fuzzMatches(tc, ret, source.loose || fm.loose) {
+						continue Returned
+					}
+				}
+			}
+			t.Errorf("included provider %s has no source for returned %s", fm, tc)
+		}
+		if fm.mustConsume {
+		Output:
+			for _, tc := range fm.flows[outputParams] {
+				for _, consumer := range funcs[p+1:] {
+					if !consumer.include {
+						continue
+					}
+					for _, in := range consumer.flows[inputParams] {
+						if fuzzMatches(in, tc, fm.loose) {
+							continue Output
+						}
+					}
+				}
+				t.Errorf("included MustConsume provider %s has no consumer for output %s", fm, tc)
+			}
+		}
+		if fm.consumptionOptional {
+			continue
+		}
+	Return:
+		for _, tc := range fm.flows[returnParams] {
+			if tc == noTypeCode {
+				continue
+			}
+			for _, consumer := range funcs[:p] {
+				if !consumer.include {
+					continue
+				}
+				for _, ret := range consumer.flows[returnedParams] {
+					if fuzzMatches(ret, tc, fm.loose || consumer.loose) {
+						continue Return
+					}
+				}
+			}
+			t.Errorf("included provider %s has no consumer for return %s", fm, tc)
+		}
+	}
+}
+
+func fuzzChainTest(t *testing.T, data []byte) {
+	b := fuzzBytes(data)
+	fc := generateFuzzChain(&b)
+	invoke, err := fc.bind()
+	if err != nil {
+		t.Skipf("cannot bind: %s", err)
+	}
+
+	invokeF := newProvider(reflect.New(fc.invokeType).Interface(), -1, "fuzz invoke func")
+	ch, err := fc.collection.buildChain(invokeF, nil, nil)
+	if err != nil {
+		t.Fatalf("bound but cannot build chain: %s", err)
+	}
+	if err := computeDependenciesAndInclusion(ch.funcs, nil, nil); err != nil {
+		t.Fatalf("bound but cannot compute inclusion: %s", err)
+	}
+	checkFuzzInvariants(t, ch.funcs)
+
+	first := fc.call(invoke)
+	if again := fc.call(invoke); again != first {
+		t.Errorf("invoke is not deterministic: %s vs %s", first, again)
+	}
+	rebound, err := fc.bind()
+	if err != nil {
+		t.Fatalf("second bind failed: %s", err)
+	}
+	if again := fc.call(rebound); again != first {
+		t.Errorf("rebound invoke is not deterministic: %s vs %s", first, again)
+	}
+}
+
+func fuzzSeeds() [][]byte {
+	r := rand.New(rand.NewSource(1))
+	seeds := make([][]byte, 200)
+	for i := range seeds {
+		seeds[i] = make([]byte, 64)
+		_, _ = r.Read(seeds[i])
+	}
+	return seeds
+}
+
+func FuzzChains(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(fuzzChainTest)
+}
+
+// TestFuzzSeedsBind makes sure that the seed corpus tests something:
+// seeds whose chains cannot be bound are skipped and the MustConsume
+// invariant is only checked when a MustConsume provider is included.
+func TestFuzzSeedsBind(t *testing.T) {
+	var bound, mustConsume int
+	for _, seed := range fuzzSeeds() {
+		b := fuzzBytes(seed)
+		fc := generateFuzzChain(&b)
+		if _, err := fc.bind(); err != nil {
+			continue
+		}
+		bound++
+		invokeF := newProvider(reflect.New(fc.invokeType).Interface(), -1, "fuzz invoke func")
+		ch, err := fc.collection.buildChain(invokeF, nil, nil)
+		if err != nil {
+			t.Fatalf("bound but cannot build chain: %s", err)
+		}
+		if err := computeDependenciesAndInclusion(ch.funcs, nil, nil); err != nil {
+			t.Fatalf("bound but cannot compute inclusion: %s", err)
+		}
+		for _, fm := range ch.funcs {
+			if fm.mustConsume && fm.include {
+				mustConsume++
+				break
+			}
+		}
+	}
+	t.Logf("%d of %d seeds bind, %d with a MustConsume provider", bound, len(fuzzSeeds()), mustConsume)
+	if bound*3 < len(fuzzSeeds())*2 {
+		t.Errorf("only %d of %d seeds bind", bound, len(fuzzSeeds()))
+	}
+	if mustConsume == 0 {
+		t.Errorf("no seed includes a MustConsume provider")
+	}
+}
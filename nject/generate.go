@@ -71,24 +71,46 @@ func generateParameterMap(
 }
 
 // generateInputMapper returns a function that copies values from valueCollection to an array of reflect.Value
-func generateInputMapper(fm *provider, start int, param flowType, rmap map[typeCode]typeCode, vmap map[typeCode]int, purpose string) (func(valueCollection) []reflect.Value, error) {
+func generateInputMapper(fm *provider, start int, param flowType, rmap map[typeCode]typeCode, vmap map[typeCode]int, purpose string, dbg *debugSink) (func(valueCollection) []reflect.Value, error) {
 	pMap, err := generateParameterMap(fm, param, start, rmap, vmap, purpose+" valueCollection->[]")
 	if err != nil {
 		return nil, err
 	}
+	var fixed map[int]reflect.Value
+	if param == inputParams {
+		fixed = optionalInputValues(fm)
+	}
+	// values from providers annotated with ConvertibleTo, AutoConvert,
+	// or AdaptPointers are converted to the parameter type
+	convert := make(map[int]func(reflect.Value) reflect.Value)
+	for i, tc := range fm.flows[param] {
+		if i < start || tc == noTypeCode {
+			continue
+		}
+		if t := tc.Type(); t != pMap.types[i] && t.Kind() != reflect.Interface {
+			convert[i] = converter(pMap.types[i], t)
+		}
+	}
 
 	return func(v valueCollection) []reflect.Value {
-		if debugEnabled() {
-			debugf("%s: %s [%s] numIn:%d, m:%v", fm, param, formatFlow(fm.flows[param]), pMap.len, pMap.vcIndex)
+		if dbg.enabled() {
+			dbg.debugf("%s: %s [%s] numIn:%d, m:%v", fm, param, dbg.formatFlow(fm.flows[param]), pMap.len, pMap.vcIndex)
 		}
-		dumpValueArray(v, "", vmap)
+		dbg.dumpValueArray(v, "", vmap)
 		in := make([]reflect.Value, pMap.len)
 		for i := start; i < pMap.len; i++ {
+			if value, ok := fixed[i]; ok {
+				in[i] = value
+				continue
+			}
 			if pMap.vcIndex[i] != -1 {
 				in[i] = v[pMap.vcIndex[i]]
 				if !in[i].IsValid() {
 					in[i] = reflect.Zero(pMap.types[i])
 				}
+				if c, ok := convert[i]; ok {
+					in[i] = c(in[i])
+				}
 			}
 		}
 		return in
@@ -96,11 +118,18 @@ func generateInputMapper(fm *provider, start int, param flowType, rmap map[typeC
 }
 
 // generateOutputMapper returns a function that copies values from an array of reflect.Value to a valueCollection
-func generateOutputMapper(fm *provider, start int, param flowType, vmap map[typeCode]int, purpose string) (func(valueCollection, []reflect.Value), error) {
+func generateOutputMapper(fm *provider, start int, param flowType, vmap map[typeCode]int, purpose string, dbg *debugSink) (func(valueCollection, []reflect.Value), error) {
 	pMap, err := generateParameterMap(fm, param, start, nil, vmap, purpose+" []->valueCollection")
 	if err != nil {
 		return nil, err
 	}
+	if param == outputParams {
+		for i, tc := range fm.flows[param] {
+			if fm.suppressedOutputs[tc] {
+				pMap.vcIndex[i] = -1
+			}
+		}
+	}
 	return func(v valueCollection, out []reflect.Value) {
 		for i := start; i < pMap.len; i++ {
 			if pMap.vcIndex[i] != -1 {
@@ -113,7 +142,7 @@ func generateOutputMapper(fm *provider, start int, param flowType, vmap map[type
 	}, nil
 }
 
-func makeZeroer(fm *provider, vMap map[typeCode]int, mustZero []typeCode, context string) (func(v valueCollection), error) {
+func makeZeroer(fm *provider, vMap map[typeCode]int, mustZero []typeCode, context string, dbg *debugSink) (func(v valueCollection), error) {
 	zeroMap := make(map[int]reflect.Type)
 	newMap := make(map[int]reflect.Type)
 	done := make(map[typeCode]bool)
@@ -144,8 +173,8 @@ func makeZeroer(fm *provider, vMap map[typeCode]int, mustZero []typeCode, contex
 	}, nil
 }
 
-func makeZero(fm *provider, vMap map[typeCode]int, upCount int, mustZero []typeCode) (func() valueCollection, error) {
-	zeroer, err := makeZeroer(fm, vMap, mustZero, "needed if inner() doesn't get called")
+func makeZero(fm *provider, vMap map[typeCode]int, upCount int, mustZero []typeCode, dbg *debugSink) (func() valueCollection, error) {
+	zeroer, err := makeZeroer(fm, vMap, mustZero, "needed if inner() doesn't get called", dbg)
 	if err != nil {
 		return nil, err
 	}
@@ -170,50 +199,75 @@ func generateWrappers(
 	downVmap map[typeCode]int, // value collection map for variables passed down
 	upVmap map[typeCode]int, // value collection map for return values coming up
 	upCount int, // size of value collection to be returned (if it needs to be created)
+	adapting bool, // some provider in the chain has AdaptPointers
+	dbg *debugSink,
 ) error {
+	if fm.lazy != nil && (fm.class == injectorFunc || fm.class == staticInjectorFunc) {
+		return generateLazyWrapper(fm, downVmap, dbg)
+	}
 	fv := reflect.ValueOf(fm.fn)
+	trace := makeTracer(downVmap)
 
 	switch fm.class {
 	case finalFunc:
-		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in")
+		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
 		if err != nil {
 			return err
 		}
-		upMap, err := generateOutputMapper(fm, 0, returnParams, upVmap, "up")
+		upMap, err := generateOutputMapper(fm, 0, returnParams, upVmap, "up", dbg)
 		if err != nil {
 			return err
 		}
+		if !trace.enabled() {
+			fm.wrapEndpoint = func(downV valueCollection) valueCollection {
+				in := inMap(downV)
+				upV := make(valueCollection, upCount)
+				out := fv.Call(in)
+				upMap(upV, out)
+				return upV
+			}
+			break
+		}
 		fm.wrapEndpoint = func(downV valueCollection) valueCollection {
 			in := inMap(downV)
 			upV := make(valueCollection, upCount)
-			upMap(upV, fv.Call(in))
+			out := fv.Call(in)
+			if t := trace.from(downV); t != nil {
+				t.record(fm, "returned", fm.flows[returnParams], out, nil)
+			}
+			upMap(upV, out)
 			return upV
 		}
 
 	case wrapperFunc:
-		inMap, err := generateInputMapper(fm, 1, inputParams, fm.downRmap, downVmap, "in") // parmeters to the middleware handler
+		inMap, err := generateInputMapper(fm, 1, inputParams, fm.downRmap, downVmap, "in", dbg) // parmeters to the middleware handler
 		if err != nil {
 			return err
 		}
-		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out") // parameters to inner()
+		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg) // parameters to inner()
 		if err != nil {
 			return err
 		}
-		upMap, err := generateOutputMapper(fm, 0, returnParams, upVmap, "up") // return values from middleward handler
+		upMap, err := generateOutputMapper(fm, 0, returnParams, upVmap, "up", dbg) // return values from middleward handler
 		if err != nil {
 			return err
 		}
-		retMap, err := generateInputMapper(fm, 0, returnedParams, fm.upRmap, upVmap, "ret") // return values from inner()
+		retMap, err := generateInputMapper(fm, 0, returnedParams, fm.upRmap, upVmap, "ret", dbg) // return values from inner()
 		if err != nil {
 			return err
 		}
-		zero, err := makeZero(fm, upVmap, upCount, fm.mustZeroIfInnerNotCalled)
+		zero, err := makeZero(fm, upVmap, upCount, fm.mustZeroIfInnerNotCalled, dbg)
 		if err != nil {
 			return err
 		}
+		deepCopy := makeDeepCopyPlan(downVmap)
+		var catch func(func(valueCollection) valueCollection, valueCollection) valueCollection
+		if adapting {
+			catch = catchNilPointer(zero, upVmap)
+		}
 		fm.wrapWrapper = func(downV valueCollection, next func(valueCollection) valueCollection) valueCollection {
 			var upV valueCollection
-			downVCopy := downV.Copy()
+			var downVCopy valueCollection
 			callCount := 0
 
 			rTypes := make([]reflect.Type, len(fm.flows[returnedParams]))
@@ -223,14 +277,28 @@ func generateWrappers(
 
 			// this is not built outside WrapWrapper for thread safety
 			inner := func(i []reflect.Value) []reflect.Value {
-				if callCount > 0 {
-					for i, val := range downVCopy {
-						downV[i] = val
-					}
+				// Nothing has changed downV before the first call so the
+				// snapshot that every call starts from is taken then
+				// rather than when the wrapper starts: wrappers that do
+				// not call inner() skip it.  Every call, including the
+				// first, gets its own deep copies so that nothing done
+				// downstream changes the values in the snapshot.
+				if callCount == 0 {
+					downVCopy = downV.Copy()
+				} else {
+					copy(downV, downVCopy)
 				}
+				deepCopy.apply(downV)
 				callCount++
+				if t := trace.from(downV); t != nil {
+					t.record(fm, "provided", fm.flows[outputParams], i, nil)
+				}
 				outMap(downV, i)
-				upV = next(downV)
+				if catch != nil {
+					upV = catch(next, downV)
+				} else {
+					upV = next(downV)
+				}
 				r := retMap(upV)
 				for i, v := range r {
 					if rTypes[i].Kind() == reflect.Interface {
@@ -245,20 +313,37 @@ func generateWrappers(
 			if callCount == 0 {
 				upV = zero()
 			}
+			if t := trace.from(downV); t != nil {
+				t.record(fm, "returned", fm.flows[returnParams], out, nil)
+			}
 			upMap(upV, out)
 			return upV
 		}
+		if fm.timeout > 0 {
+			outTypes := typesOut(fv.Type())
+			errorIndex := typeIndex(outTypes, errorType)
+			fm.wrapWrapper = timeoutWrapper(fm.timeout, fm.wrapWrapper, zero, func(upV valueCollection) {
+				out := make([]reflect.Value, len(outTypes))
+				for i, typ := range outTypes {
+					out[i] = reflect.Zero(typ)
+				}
+				err := reflect.New(errorType).Elem()
+				err.Set(reflect.ValueOf(TimeoutError{Duration: fm.timeout}))
+				out[errorIndex] = err
+				upMap(upV, out)
+			})
+		}
 
 	case fallibleInjectorFunc:
-		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in")
+		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
 		if err != nil {
 			return err
 		}
-		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out")
+		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg)
 		if err != nil {
 			return err
 		}
-		zero, err := makeZero(fm, upVmap, upCount, fm.mustZeroIfInnerNotCalled)
+		zero, err := makeZero(fm, upVmap, upCount, fm.mustZeroIfInnerNotCalled, dbg)
 		if err != nil {
 			return err
 		}
@@ -271,40 +356,60 @@ func generateWrappers(
 			in := inMap(v)
 			out := fv.Call(in)
 			if out[errorIndex].Interface() != nil {
+				if t := trace.from(v); t != nil {
+					t.record(fm, "short-circuit", nil, nil, out[errorIndex].Interface().(error))
+				}
 				upV := zero()
 				upV[upVerrorIndex] = out[errorIndex].Convert(errorType)
-				if debugEnabled() {
-					debugln("ABOUT TO RETURN ERROR")
-					dumpValueArray(upV, "error return", upVmap)
+				if dbg.enabled() {
+					dbg.debugln("ABOUT TO RETURN ERROR")
+					dbg.dumpValueArray(upV, "error return", upVmap)
 				}
 				return true, upV
 			}
-			outMap(v, append(out[:errorIndex], out[errorIndex+1:]...))
-			debugln("ABOUT TO RETURN NIL")
+			out = append(out[:errorIndex], out[errorIndex+1:]...)
+			if t := trace.from(v); t != nil {
+				t.record(fm, "provided", fm.flows[outputParams], out, nil)
+			}
+			outMap(v, out)
+			dbg.debugln("ABOUT TO RETURN NIL")
 			return false, nil
 		}
 
 	case injectorFunc:
-		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in")
+		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
 		if err != nil {
 			return err
 		}
-		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out")
+		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg)
 		if err != nil {
 			return err
 		}
+		if !trace.enabled() {
+			fm.wrapFallibleInjector = func(v valueCollection) (bool, valueCollection) {
+				in := inMap(v)
+				out := fv.Call(in)
+				outMap(v, out)
+				return false, nil
+			}
+			break
+		}
 		fm.wrapFallibleInjector = func(v valueCollection) (bool, valueCollection) {
 			in := inMap(v)
-			outMap(v, fv.Call(in))
+			out := fv.Call(in)
+			if t := trace.from(v); t != nil {
+				t.record(fm, "provided", fm.flows[outputParams], out, nil)
+			}
+			outMap(v, out)
 			return false, nil
 		}
 
 	case staticInjectorFunc:
-		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in")
+		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
 		if err != nil {
 			return err
 		}
-		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out")
+		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg)
 		if err != nil {
 			return err
 		}
@@ -322,11 +427,11 @@ func generateWrappers(
 		}
 
 	case fallibleStaticInjectorFunc:
-		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in")
+		inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
 		if err != nil {
 			return err
 		}
-		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out")
+		outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg)
 		if err != nil {
 			return err
 		}
@@ -334,13 +439,13 @@ func generateWrappers(
 		if err != nil {
 			return err
 		}
-		zeroer, err := makeZeroer(fm, downVmap, fm.mustZeroIfRemainderSkipped, "need to fill in values set in skippped functions")
+		zeroer, err := makeZeroer(fm, downVmap, fm.mustZeroIfRemainderSkipped, "need to fill in values set in skippped functions", dbg)
 		if err != nil {
 			return err
 		}
 		cacheLookup := generateCache(fm.id, fv, len(inputParams))
 		fm.wrapStaticInjector = func(v valueCollection) error {
-			debugf("RUNNING %s", fm)
+			dbg.debugf("RUNNING %s", fm)
 			in := inMap(v)
 			var out []reflect.Value
 			if fm.memoized {
@@ -352,20 +457,20 @@ func generateWrappers(
 			out[errorIndex] = out[errorIndex].Convert(errorType)
 			outMap(v, out)
 			if err != nil {
-				if debugEnabled() {
-					debugf("Zeroing for %s", fm)
-					dumpValueArray(v, "BEFORE", downVmap)
+				if dbg.enabled() {
+					dbg.debugf("Zeroing for %s", fm)
+					dbg.dumpValueArray(v, "BEFORE", downVmap)
 				}
 				zeroer(v)
-				if debugEnabled() {
-					dumpValueArray(v, "AFTER", downVmap)
-					debugf("RETURNING %v", err)
+				if dbg.enabled() {
+					dbg.dumpValueArray(v, "AFTER", downVmap)
+					dbg.debugf("RETURNING %v", err)
 				}
 				return err.(error)
 			}
-			if debugEnabled() {
-				debugf("NOT zeroing for %s", fm)
-				debugf("RETURNING nil")
+			if dbg.enabled() {
+				dbg.debugf("NOT zeroing for %s", fm)
+				dbg.debugf("RETURNING nil")
 			}
 			return nil
 		}
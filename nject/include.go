@@ -11,6 +11,7 @@ type includeWorkingData struct {
 	usedBy          []*provider
 	usedByDetail    map[flowType]map[typeCode][]*provider
 	mustConsumeFlow map[flowType]bool
+	missingOptional map[typeCode]bool
 	excluded        error
 }
 
@@ -62,10 +63,10 @@ type includeWorkingData struct {
 //	fm.wanted
 //
 
-func computeDependenciesAndInclusion(funcs []*provider, initF *provider) error {
-	debugln("initial set of functions")
+func computeDependenciesAndInclusion(funcs []*provider, initF *provider, dbg *debugSink) error {
+	dbg.debugln("initial set of functions")
 	for _, fm := range funcs {
-		debugf("\t%s", fm)
+		dbg.debugf("\t%s", fm)
 		fm.d.mustConsumeFlow = make(map[flowType]bool)
 		if fm.mustConsume {
 			fm.d.mustConsumeFlow[outputParams] = true
@@ -82,37 +83,37 @@ func computeDependenciesAndInclusion(funcs []*provider, initF *provider) error {
 			fm.wanted = true
 		}
 	}
-	debugln("calculate flows, initial")
-	err := providesReturns(funcs, initF)
+	dbg.debugln("calculate flows, initial")
+	err := providesReturns(funcs, initF, dbg)
 	if err != nil {
 		return err
 	}
 
-	debugln("check chain validity, no provider excluded")
-	err = validateChainMarkIncludeExclude(funcs, true)
+	dbg.debugln("check chain validity, no provider excluded")
+	err = validateChainMarkIncludeExclude(funcs, true, dbg)
 	if err != nil {
 		return err
 	}
 
 	for _, fm := range funcs {
 		if fm.cannotInclude != nil {
-			debugf("Excluding %s: %s", fm, fm.cannotInclude)
+			dbg.debugf("Excluding %s: %s", fm, fm.cannotInclude)
 			fm.d.excluded = fm.cannotInclude
 			fm.include = false
 		}
 	}
 
-	eliminateUnused(funcs)
+	eliminateUnused(funcs, dbg)
 
 	// Attempt to eliminate providers
 	postCheck := make([]*provider, 0, len(funcs))
-	for _, fm := range proposeEliminations(funcs) {
+	for _, fm := range proposeEliminations(funcs, dbg) {
 		if fm.d.excluded != nil {
 			continue
 		}
-		debugf("check chain validity, excluding %s", fm)
+		dbg.debugf("check chain validity, excluding %s", fm)
 		fm.d.excluded = fmt.Errorf("excluded to see what happens")
-		err := validateChainMarkIncludeExclude(funcs, false)
+		err := validateChainMarkIncludeExclude(funcs, false, dbg)
 		if err == nil {
 			fm.d.excluded = fmt.Errorf("not required, not desired, not necessary")
 		} else {
@@ -121,35 +122,35 @@ func computeDependenciesAndInclusion(funcs []*provider, initF *provider) error {
 		}
 	}
 
-	eliminateUnused(postCheck)
+	eliminateUnused(postCheck, dbg)
 
-	debugln("final set of functions")
+	dbg.debugln("final set of functions")
 	for _, fm := range funcs {
 		if fm.d.excluded == nil {
 			fm.cannotInclude = nil
-			debugf("\tinclude %s --- %s", fm, fm.whyIncluded)
+			dbg.debugf("\tinclude %s --- %s", fm, fm.whyIncluded)
 		} else {
 			if fm.cannotInclude == nil {
 				fm.cannotInclude = fm.d.excluded
 			}
-			debugf("\texclude %s --- %s", fm, fm.cannotInclude)
+			dbg.debugf("\texclude %s --- %s", fm, fm.cannotInclude)
 		}
 	}
 
-	debugln("final calculate flows")
-	err = providesReturns(funcs, initF)
+	dbg.debugln("final calculate flows")
+	err = providesReturns(funcs, initF, dbg)
 	if err != nil {
 		return fmt.Errorf("internal error: uh oh")
 	}
-	debugf("final check chain validity")
-	err = validateChainMarkIncludeExclude(funcs, true)
+	dbg.debugf("final check chain validity")
+	err = validateChainMarkIncludeExclude(funcs, true, dbg)
 	if err != nil {
 		return fmt.Errorf("internal error: uh oh #2")
 	}
 	return nil
 }
 
-func validateChainMarkIncludeExclude(funcs []*provider, canRemoveDesired bool) error {
+func validateChainMarkIncludeExclude(funcs []*provider, canRemoveDesired bool, dbg *debugSink) error {
 	remainingFuncs := make([]*provider, 0, len(funcs))
 	for _, fm := range funcs {
 		if fm.d.excluded == nil {
@@ -164,49 +165,49 @@ func validateChainMarkIncludeExclude(funcs []*provider, canRemoveDesired bool) e
 			fm.include = false
 		}
 	}
-	return checkFlows(remainingFuncs, len(funcs), canRemoveDesired)
+	return checkFlows(remainingFuncs, len(funcs), canRemoveDesired, dbg)
 }
 
-func checkFlows(funcs []*provider, numFuncs int, canRemoveDesired bool) error {
+func checkFlows(funcs []*provider, numFuncs int, canRemoveDesired bool, dbg *debugSink) error {
 	todo := funcs
 	redo := make([]*provider, 0, len(funcs)*6)
 	for len(todo) > 0 {
 		seen := make([]bool, numFuncs)
-		debugf("\tstarting check pass with %d providers", len(todo))
+		dbg.debugf("\tstarting check pass with %d providers", len(todo))
 	Todo:
 		for _, fm := range todo {
 			if seen[fm.chainPosition] {
-				debugf("\talready done: %s", fm)
+				dbg.debugf("\talready done: %s", fm)
 				continue
 			}
 			seen[fm.chainPosition] = true
 			if fm.cannotInclude != nil {
 				if fm.required {
-					debugf("\tchain invalid required but: %s: %s", fm, fm.cannotInclude)
-					return fm.errorf("required but %s", fm.cannotInclude)
+					dbg.debugf("\tchain invalid required but: %s: %s", fm, fm.cannotInclude)
+					return fmt.Errorf("%s: required but %w", fm, fm.cannotInclude)
 				}
 				if (fm.wanted || fm.desired) && !canRemoveDesired && fm.d.excluded == nil {
-					debugf("\tchain invalid wanted but: %s: %s", fm, fm.cannotInclude)
-					return fm.errorf("wanted but %s", fm.cannotInclude)
+					dbg.debugf("\tchain invalid wanted but: %s: %s", fm, fm.cannotInclude)
+					return fmt.Errorf("%s: wanted but %w", fm, fm.cannotInclude)
 				}
 				if fm.include {
-					debugf("\tprovider now excluded: %s: %s", fm, fm.cannotInclude)
+					dbg.debugf("\tprovider now excluded: %s: %s", fm, fm.cannotInclude)
 					fm.include = false
 					redo = append(redo, fm.d.usedBy...)
 				} else {
-					debugf("\tprovider already excluded: %s: %s", fm, fm.cannotInclude)
+					dbg.debugf("\tprovider already excluded: %s: %s", fm, fm.cannotInclude)
 				}
 				continue
 			}
 
-			debugf("\tchecking %s", fm)
+			dbg.debugf("\tchecking %s", fm)
 
 			// This checks for inputs with no provider
 			for param, errors := range fm.d.usesError {
 				for tc, err := range errors {
 					fm.cannotInclude = err
 					redo = append(redo, fm)
-					debugf("\t\trequire error on %s %s: %s", param, tc, err)
+					dbg.debugf("\t\trequire error on %s %s: %s", param, tc, err)
 					continue Todo
 				}
 			}
@@ -218,15 +219,19 @@ func checkFlows(funcs []*provider, numFuncs int, canRemoveDesired bool) error {
 					var extra string
 					for _, p := range plist {
 						if p.include {
-							debugf("\t\t\tfound source for %s %s: %s", param, tc, p)
+							dbg.debugf("\t\t\tfound source for %s %s: %s", param, tc, p)
 							continue Source
 						}
-						debugf("\t\t\tcannot provide %s %s: %s: %s", param, tc, p, p.cannotInclude)
+						dbg.debugf("\t\t\tcannot provide %s %s: %s: %s", param, tc, p, p.cannotInclude)
 						extra = fmt.Sprintf(" (not provided by %s because %s)", p, p.cannotInclude)
 					}
+					if param == inputParams && fm.optionalInputs {
+						dbg.debugf("\t\t\tno source for optional %s %s", param, tc)
+						continue Source
+					}
 					fm.cannotInclude = fmt.Errorf("no provider for %s in %s%s", tc, param, extra)
 					redo = append(redo, fm)
-					debugf("\t\tno source %s %s  %s: %s", param, tc, fm, fm.cannotInclude)
+					dbg.debugf("\t\tno source %s %s  %s: %s", param, tc, fm, fm.cannotInclude)
 					continue Todo
 				}
 			}
@@ -241,54 +246,55 @@ func checkFlows(funcs []*provider, numFuncs int, canRemoveDesired bool) error {
 					var extra string
 					for _, p := range fm.d.usedByDetail[param][tc] {
 						if p.include {
-							debugf("\t\t\tfound consumer of %s %s: %s", param, tc, p)
+							dbg.debugf("\t\t\tfound consumer of %s %s: %s", param, tc, p)
 							continue Param
 						}
-						debugf("\t\t\tcannot consume %s %s: %s: %s", param, tc, p, p.cannotInclude)
+						dbg.debugf("\t\t\tcannot consume %s %s: %s: %s", param, tc, p, p.cannotInclude)
 						extra = fmt.Sprintf(" (not consumed by %s because %s)", p, p.cannotInclude)
 					}
 					fm.cannotInclude = fmt.Errorf("no consumer for %s in %s%s", tc, param, extra)
 					redo = append(redo, fm)
-					debugf("\t\tnot consumed %s %s %s: %s", param, tc, fm, fm.cannotInclude)
+					dbg.debugf("\t\tnot consumed %s %s %s: %s", param, tc, fm, fm.cannotInclude)
 					continue Todo
 				}
 			}
-			debugf("\t\tprovider still valid: %s", fm)
+			dbg.debugf("\t\tprovider still valid: %s", fm)
 		}
 		todo = redo
 		redo = make([]*provider, 0, len(redo)*2)
 	}
-	debugln("\tchain is valid")
+	dbg.debugln("\tchain is valid")
 	return nil
 }
 
-func providesReturns(funcs []*provider, initF *provider) error {
-	debugln("calculating provides/returns")
+func providesReturns(funcs []*provider, initF *provider, dbg *debugSink) error {
+	dbg.debugln("calculating provides/returns")
 	for _, fm := range funcs {
 		fm.d.usedByDetail = make(map[flowType]map[typeCode][]*provider)
 		fm.d.usesDetail = make(map[flowType]map[typeCode][]*provider)
 		fm.d.uses = nil
 		fm.d.usesError = make(map[flowType]map[typeCode]error)
 		fm.d.usedBy = nil
+		fm.d.missingOptional = make(map[typeCode]bool)
 	}
 	provide := make(interfaceMap)
 	for i, fm := range funcs {
 		if fm.cannotInclude != nil {
-			debugf("\tskipping on downard path %s: %s", fm, fm.cannotInclude)
+			dbg.debugf("\tskipping on downard path %s: %s", fm, fm.cannotInclude)
 			continue
 		}
 		if fm.class == invokeFunc && initF != nil {
 			initF.bypassRmap = make(map[typeCode]typeCode)
-			err := requireParameters(initF, provide, bypassParams, outputParams, initF.bypassRmap, "returned value")
+			err := requireParameters(initF, provide, bypassParams, outputParams, initF.bypassRmap, "returned value", dbg)
 			if err != nil {
 				return err
 			}
 		}
-		err := requireParameters(fm, provide, inputParams, outputParams, fm.downRmap, "input")
+		err := requireParameters(fm, provide, inputParams, outputParams, fm.downRmap, "input", dbg)
 		if err != nil {
 			return err
 		}
-		provideParameters(fm, provide, outputParams, inputParams, i+2)
+		provideParameters(fm, provide, outputParams, inputParams, i+2, dbg)
 	}
 
 	// Upwards chain
@@ -296,14 +302,14 @@ func providesReturns(funcs []*provider, initF *provider) error {
 	for i := len(funcs) - 1; i >= 0; i-- {
 		fm := funcs[i]
 		if fm.cannotInclude != nil {
-			debugf("\tskipping on upward path %s: %s", fm, fm.cannotInclude)
+			dbg.debugf("\tskipping on upward path %s: %s", fm, fm.cannotInclude)
 			continue
 		}
-		err := requireParameters(fm, returns, returnedParams, returnParams, fm.upRmap, "expected return")
+		err := requireParameters(fm, returns, returnedParams, returnParams, fm.upRmap, "expected return", dbg)
 		if err != nil {
 			return err
 		}
-		provideParameters(fm, returns, returnParams, returnedParams, len(funcs)-i+2)
+		provideParameters(fm, returns, returnParams, returnedParams, len(funcs)-i+2, dbg)
 	}
 	return nil
 }
@@ -314,8 +320,9 @@ func provideParameters(
 	param flowType,
 	inParam flowType,
 	position int,
+	dbg *debugSink,
 ) {
-	debugf("\tproviding %s for %s", param, fm)
+	dbg.debugf("\tproviding %s for %s", param, fm)
 	incoming := make(map[typeCode]bool)
 	for _, in := range fm.flows[inParam] {
 		incoming[in] = true
@@ -323,10 +330,10 @@ func provideParameters(
 	fm.d.usedByDetail[param] = make(map[typeCode][]*provider)
 	for _, out := range fm.flows[param] {
 		if out == noTypeCode {
-			debugln("\t\tskipping no-type")
+			dbg.debugln("\t\tskipping no-type")
 			continue
 		}
-		debugf("\t\tproviding %s from %s", out, fm)
+		dbg.debugf("\t\tproviding %s from %s", out, fm)
 		available.Add(out, position, fm)
 	}
 }
@@ -338,18 +345,30 @@ func requireParameters(
 	outParam flowType,
 	rMap map[typeCode]typeCode,
 	purpose string,
+	dbg *debugSink,
 ) error {
-	debugf("\trequire %s for %s", purpose, fm)
+	dbg.debugf("\trequire %s for %s", purpose, fm)
 	fm.d.usesError[param] = make(map[typeCode]error)
 	fm.d.usesDetail[param] = make(map[typeCode][]*provider)
 	for _, in := range fm.flows[param] {
 		if in == noTypeCode {
-			debugf("\t\tskipping %s: not a real type", in)
+			dbg.debugf("\t\tskipping %s: not a real type", in)
+			continue
+		}
+		optional := fm.optionalInputs && param == inputParams
+		if optional && in == presenceTypeCode {
+			rMap[in] = in
+			continue
+		}
+		found, dependsOn, err := available.bestMatch(in, purpose, fm.loose && param == returnedParams)
+		if err != nil && optional {
+			dbg.debugf("\t\tmissing optional %s %s: %s", param, in, err)
+			rMap[in] = in
+			fm.d.missingOptional[in] = true
 			continue
 		}
-		found, dependsOn, err := available.bestMatch(in, purpose)
 		if err != nil {
-			debugf("\t\tcannot find %s %s: %s", param, in, err)
+			dbg.debugf("\t\tcannot find %s %s: %s", param, in, err)
 			fm.d.usesError[param][in] = err
 			continue
 		}
@@ -357,14 +376,24 @@ func requireParameters(
 			return fmt.Errorf("internal error: dependsOn should not be empty for %s %s in %s", param, in, fm)
 		}
 		rMap[in] = found
+		// Values that are converted and values that are returned to a Loose
+		// consumer are consumed as the provided type.  Other interface
+		// matches are recorded as the consumed interface.
+		usedAs := in
+		if isConversion(in, found) {
+			dbg.debugf("\t\tconverting %s from %s", in, found)
+			usedAs = found
+		} else if fm.loose && param == returnedParams {
+			usedAs = found
+		}
 		for _, dep := range dependsOn {
-			debugf("\t\tadding dependency for %s: uses %s", in, dep)
+			dbg.debugf("\t\tadding dependency for %s: uses %s", in, dep)
 			fm.d.usesDetail[param][in] = append(fm.d.usesDetail[param][in], dep)
 			fm.d.uses = append(fm.d.uses, dep)
 
-			debugf("\t\tadding used-by %s %s: %s", outParam, in, dep)
+			dbg.debugf("\t\tadding used-by %s %s: %s", outParam, in, dep)
 			dep.d.usedBy = append(dep.d.usedBy, fm)
-			dep.d.usedByDetail[outParam][in] = append(dep.d.usedByDetail[outParam][in], fm)
+			dep.d.usedByDetail[outParam][usedAs] = append(dep.d.usedByDetail[outParam][usedAs], fm)
 			if dep.d.mustConsumeFlow[outParam] {
 				fm.d.usedBy = append(fm.d.usedBy, dep)
 			}
@@ -373,8 +402,8 @@ func requireParameters(
 	return nil
 }
 
-func eliminateUnused(check []*provider) {
-	debugln("eliminate those that no longer have any consumers")
+func eliminateUnused(check []*provider, dbg *debugSink) {
+	dbg.debugln("eliminate those that no longer have any consumers")
 PostCheck:
 	for len(check) > 0 {
 		var fm *provider
@@ -384,20 +413,20 @@ PostCheck:
 		}
 		for _, dep := range fm.d.usedBy {
 			if dep.include {
-				debugf("\t%s included by %s", fm, dep)
+				dbg.debugf("\t%s included by %s", fm, dep)
 				continue PostCheck
 			}
 		}
 		fm.include = false
 		fm.cannotInclude = fmt.Errorf("not used by any remaining providers")
 		fm.d.excluded = fm.cannotInclude
-		debugf("\tno included users for: %s", fm)
+		dbg.debugf("\tno included users for: %s", fm)
 		check = append(check, fm.d.uses...)
 	}
 }
 
-func proposeEliminations(funcs []*provider) []*provider {
-	debugln("pick providers that should be considered for exclusion")
+func proposeEliminations(funcs []*provider, dbg *debugSink) []*provider {
+	dbg.debugln("pick providers that should be considered for exclusion")
 	kept := make([]bool, len(funcs))
 	for _, fg := range []struct {
 		direction  string
@@ -421,22 +450,25 @@ func proposeEliminations(funcs []*provider) []*provider {
 			var fm *provider
 			fm, toKeep = toKeep[0], toKeep[1:]
 			if keep[fm.chainPosition] {
-				debugf("\talready kept: %s", fm)
+				dbg.debugf("\talready kept: %s", fm)
 				continue
 			}
-			debugf("\tkeeping %s %s", fg.direction, fm)
+			dbg.debugf("\tkeeping %s %s", fg.direction, fm)
 			keep[fm.chainPosition] = true
 			kept[fm.chainPosition] = true
 			for _, param := range fg.flowGroups {
 				for tc, users := range fm.d.usesDetail[param] {
-					debugf("\t\tsourcing %s %s", param, tc)
+					dbg.debugf("\t\tsourcing %s %s", param, tc)
 					deps := make([]*provider, 0, len(users))
 					for _, dep := range users {
 						if dep.cannotInclude == nil && dep.d.excluded == nil {
-							debugf("\t\t\tcan get it from %s", dep)
+							dbg.debugf("\t\t\tcan get it from %s", dep)
 							deps = append(deps, dep)
 						}
 					}
+					if notDefault := notDefaultOnly(tc, deps); len(notDefault) > 0 {
+						deps = notDefault
+					}
 					if len(deps) > 0 {
 						var k *provider
 						if fg.useLast {
@@ -445,13 +477,13 @@ func proposeEliminations(funcs []*provider) []*provider {
 							k = deps[0]
 						}
 						if !keep[k.chainPosition] {
-							debugf("\t\t\tfor %s %s, keeping %s", param, tc, k)
+							dbg.debugf("\t\t\tfor %s %s, keeping %s", param, tc, k)
 							toKeep = append(toKeep, k)
 							if k.whyIncluded == "" {
 								k.whyIncluded = fmt.Sprintf("used by %s (%s)", fm, fm.whyIncluded)
 							}
 						} else {
-							debugf("\t\t\tfor %s %s, no need to keep %s", param, tc, k)
+							dbg.debugf("\t\t\tfor %s %s, no need to keep %s", param, tc, k)
 						}
 					}
 				}
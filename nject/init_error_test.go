@@ -0,0 +1,153 @@
+package nject
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticFailureChain(fail *bool, calls *int, final interface{}) *Collection {
+	return Sequence("static",
+		MustCache(func(s s1) (s2, TerminalError) {
+			*calls++
+			if *fail {
+				return "", errors.New("static failed")
+			}
+			return s2(s) + " static", nil
+		}),
+		final,
+	)
+}
+
+func finalWithError(s s2, s3 s3) (s4, error) { return s4(string(s) + " " + string(s3)), nil }
+
+func TestStaticFailureWithoutInitError(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		fail, calls := true, 0
+		var initFunc func(s1)
+		var invoke func(s3) (s4, error)
+		require.NoError(t, staticFailureChain(&fail, &calls, finalWithError).Bind(&invoke, &initFunc))
+		initFunc("x")
+		_, err := invoke("run")
+		assert.EqualError(t, err, "static failed")
+
+		fail = false
+		initFunc("x")
+		_, err = invoke("run")
+		assert.EqualError(t, err, "static failed", "init is not retried by default")
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestStaticFailureWithoutErrorReturnPanics(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		fail, calls := true, 0
+		var initFunc func(s1)
+		var invoke func(s3) s4
+		require.NoError(t, staticFailureChain(&fail, &calls, func(s s2, s3 s3) s4 { return s4(string(s) + " " + string(s3)) }).Bind(&invoke, &initFunc))
+		initFunc("x")
+		assert.PanicsWithValue(t, "cannot invoke static: initialization failed: static failed", func() {
+			invoke("run")
+		})
+	})
+}
+
+func TestStaticFailureRetryableInit(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		fail, calls := true, 0
+		var initFunc func(s1)
+		var invoke func(s3) (s4, error)
+		require.NoError(t, staticFailureChain(&fail, &calls, finalWithError).Bind(&invoke, &initFunc, RetryableInit()))
+		initFunc("x")
+		_, err := invoke("run")
+		assert.EqualError(t, err, "static failed")
+
+		fail = false
+		initFunc("y")
+		got, err := invoke("run")
+		require.NoError(t, err)
+		assert.Equal(t, s4("y static run"), got)
+
+		initFunc("z")
+		assert.Equal(t, 2, calls, "init is not repeated after it succeeds")
+	})
+}
+
+func TestStaticFailureRetryableInitWithoutInitFunc(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		fail, calls := true, 0
+		var invoke func(s3) (s4, error)
+		require.NoError(t, Sequence("no init", s1("lit"), staticFailureChain(&fail, &calls, finalWithError)).Bind(&invoke, nil, RetryableInit()))
+		_, err := invoke("run")
+		assert.EqualError(t, err, "static failed")
+
+		fail = false
+		got, err := invoke("run")
+		require.NoError(t, err)
+		assert.Equal(t, s4("lit static run"), got)
+		_, _ = invoke("run")
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestStaticFailureConsumedByRunChain(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		fail, calls := true, 0
+		var initFunc func(s1)
+		var invoke func(s3) (s4, error)
+		require.NoError(t, staticFailureChain(&fail, &calls, func(err error, s s2, s3 s3) (s4, error) {
+			if err != nil {
+				return "handled " + s4(err.Error()), nil
+			}
+			return s4(string(s) + " " + string(s3)), nil
+		}).Bind(&invoke, &initFunc))
+		initFunc("x")
+		got, err := invoke("run")
+		require.NoError(t, err)
+		assert.Equal(t, s4("handled static failed"), got)
+	})
+}
+
+func TestStaticFailureRetryableInitConcurrent(t *testing.T) {
+	var fail int32 = 1
+	var initFunc func(s1)
+	var invoke func(s3) (s4, error)
+	require.NoError(t, Sequence("concurrent",
+		MustCache(func(s s1) (s2, TerminalError) {
+			if atomic.LoadInt32(&fail) == 1 {
+				return "", errors.New("static failed")
+			}
+			return s2(s) + " static", nil
+		}),
+		finalWithError,
+	).Bind(&invoke, &initFunc, RetryableInit()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				got, err := invoke("run")
+				if err != nil {
+					assert.EqualError(t, err, "static failed")
+					continue
+				}
+				assert.Equal(t, s4("ok static run"), got)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		initFunc("ok")
+	}
+	atomic.StoreInt32(&fail, 0)
+	initFunc("ok")
+	wg.Wait()
+	got, err := invoke("run")
+	require.NoError(t, err)
+	assert.Equal(t, s4("ok static run"), got)
+}
@@ -0,0 +1,261 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Lazy creates a provider whose outputs are computed on demand.
+// The lazy argument must be a (nil) value of a named function type
+// that takes no arguments and returns the same types as fn.  For
+// example:
+//
+//	type LazyDB func() *sql.DB
+//
+//	nject.Lazy(LazyDB(nil), func(cfg Config) *sql.DB { ... })
+//
+// Consumers take LazyDB instead of *sql.DB.  fn is called the first time
+// that a consumer calls the LazyDB function.  After that, the same values
+// are returned.  In the RUN chain, that means fn is called at most once per
+// invocation.  In the STATIC chain, it is called at most once.
+//
+// The injectors that exist only to provide the inputs of fn are deferred
+// too: they are left out of the chain and run, in chain order, just before
+// fn is called.  An injector is deferred if everything included in the chain
+// that consumes its outputs is fn or another injector deferred for fn, and if
+// nothing that runs between it and fn provides the same types as its inputs
+// or outputs.  Wrappers and fallible injectors are never deferred and
+// neither are injectors whose outputs are also consumed by a second Lazy
+// provider: they run when the chain runs, like any other provider.
+//
+// If fn or one of its deferred injectors panics, the panic is passed on to
+// the consumer that called the LazyDB function and every later call panics
+// with the same value.
+//
+// fn cannot be a wrapper and cannot return TerminalError.  Lazy panics if
+// the types do not match.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func Lazy(lazy interface{}, fn interface{}) Provider {
+	lazyType := reflect.TypeOf(lazy)
+	return newThing(fn).modify(func(fm *provider) {
+		fm.lazy = makeLazy(lazyType, fm.fn)
+		fm.fn = fm.lazy.providerFunc()
+	})
+}
+
+// lazyProvider is the function that was annotated with Lazy
+type lazyProvider struct {
+	lazyType reflect.Type
+	fn       reflect.Value
+}
+
+func (l *lazyProvider) call(inputs []reflect.Value) []reflect.Value {
+	if l.fn.Type().IsVariadic() {
+		return l.fn.CallSlice(inputs)
+	}
+	return l.fn.Call(inputs)
+}
+
+func makeLazy(lazyType reflect.Type, fn interface{}) *lazyProvider {
+	if lazyType == nil || lazyType.Kind() != reflect.Func || lazyType.Name() == "" {
+		panic(fmt.Sprintf("Lazy requires a named function type, not %v", lazyType))
+	}
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("Lazy requires a function, not %T", fn))
+	}
+	t := v.Type()
+	if lazyType.NumIn() != 0 || lazyType.IsVariadic() {
+		panic(fmt.Sprintf("Lazy type %s must not take any arguments", lazyType))
+	}
+	if t.NumOut() == 0 || t.NumOut() != lazyType.NumOut() {
+		panic(fmt.Sprintf("Lazy type %s must return the same types as %s", lazyType, t))
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		if t.Out(i) != lazyType.Out(i) {
+			panic(fmt.Sprintf("Lazy type %s must return the same types as %s", lazyType, t))
+		}
+		if t.Out(i) == terminalErrorType {
+			panic(fmt.Sprintf("Lazy cannot be used with fallible injectors like %s", t))
+		}
+	}
+	if hasAnonymousFuncs(typesIn(t), false) {
+		panic(fmt.Sprintf("Lazy cannot be used with wrappers or other functions that take untyped functional arguments like %s", t))
+	}
+
+	return &lazyProvider{lazyType: lazyType, fn: v}
+}
+
+// providerFunc returns the function that stands in for the Lazy provider.
+// It gives the provider its type.  When the chain is bound,
+// generateLazyWrapper is used instead of calling it so that the inputs
+// can be deferred too.
+func (l *lazyProvider) providerFunc() interface{} {
+	t := l.fn.Type()
+	providerType := reflect.FuncOf(typesIn(t), []reflect.Type{l.lazyType}, t.IsVariadic())
+	return reflect.MakeFunc(providerType, func(inputs []reflect.Value) []reflect.Value {
+		return []reflect.Value{onDemand(l.lazyType, func() []reflect.Value {
+			return l.call(inputs)
+		})}
+	}).Interface()
+}
+
+// onDemand creates a function of lazyType that calls compute the first
+// time it is called and returns the same values after that.
+func onDemand(lazyType reflect.Type, compute func() []reflect.Value) reflect.Value {
+	var once sync.Once
+	var outputs []reflect.Value
+	var panicked bool
+	var panicValue interface{}
+	return reflect.MakeFunc(lazyType, func([]reflect.Value) []reflect.Value {
+		once.Do(func() {
+			panicked = true
+			defer func() {
+				if panicked {
+					panicValue = recover()
+				}
+			}()
+			outputs = compute()
+			panicked = false
+		})
+		if panicked {
+			panic(panicValue)
+		}
+		return outputs
+	})
+}
+
+// generateLazyWrapper is generateWrappers for providers annotated with
+// Lazy.  When the Lazy provider has deferred injectors, the values are
+// copied when the Lazy provider runs so that the deferred injectors and fn
+// see the values as they were at that point in the chain.
+func generateLazyWrapper(fm *provider, downVmap map[typeCode]int, dbg *debugSink) error {
+	inMap, err := generateInputMapper(fm, 0, inputParams, fm.downRmap, downVmap, "in", dbg)
+	if err != nil {
+		return err
+	}
+	outMap, err := generateOutputMapper(fm, 0, outputParams, downVmap, "out", dbg)
+	if err != nil {
+		return err
+	}
+	trace := makeTracer(downVmap)
+	provide := func(v valueCollection) {
+		var compute func() []reflect.Value
+		if len(fm.lazyDeps) == 0 {
+			in := inMap(v)
+			compute = func() []reflect.Value {
+				return fm.lazy.call(in)
+			}
+		} else {
+			snapshot := v.Copy()
+			compute = func() []reflect.Value {
+				for _, dep := range fm.lazyDeps {
+					if dep.group == staticGroup {
+						_ = dep.wrapStaticInjector(snapshot)
+					} else {
+						_, _ = dep.wrapFallibleInjector(snapshot)
+					}
+				}
+				return fm.lazy.call(inMap(snapshot))
+			}
+		}
+		out := []reflect.Value{onDemand(fm.lazy.lazyType, compute)}
+		if t := trace.from(v); t != nil {
+			t.record(fm, "provided", fm.flows[outputParams], out, nil)
+		}
+		outMap(v, out)
+	}
+	if fm.group == staticGroup {
+		fm.wrapStaticInjector = func(v valueCollection) error {
+			provide(v)
+			return nil
+		}
+	} else {
+		fm.wrapFallibleInjector = func(v valueCollection) (bool, valueCollection) {
+			provide(v)
+			return false, nil
+		}
+	}
+	return nil
+}
+
+// deferLazyDependencies finds the injectors that exist only to provide the
+// inputs of a Lazy provider.  They are marked as deferred so that they are
+// left out of the chain and recorded in the Lazy provider's lazyDeps so
+// that it can run them.  It must be called after
+// computeDependenciesAndInclusion.
+func deferLazyDependencies(funcs []*provider) {
+	for i, fm := range funcs {
+		if fm.lazy == nil || !fm.include {
+			continue
+		}
+		var class classType
+		switch fm.class {
+		case injectorFunc, staticInjectorFunc:
+			class = fm.class
+		default:
+			continue
+		}
+		deferred := map[*provider]bool{fm: true}
+		// written are the types that are provided by providers that
+		// still run in the chain between the candidate and fm
+		written := make(map[typeCode]bool)
+		var deps []*provider
+		for j := i - 1; j >= 0; j-- {
+			p := funcs[j]
+			if p.class == invokeFunc {
+				break
+			}
+			if !p.include || p.deferred {
+				continue
+			}
+			if p.class == class && canDefer(p, deferred, written) {
+				p.deferred = true
+				deferred[p] = true
+				deps = append(deps, p)
+				continue
+			}
+			for _, tc := range p.flows[outputParams] {
+				written[tc] = true
+			}
+		}
+		for a, b := 0, len(deps)-1; a < b; a, b = a+1, b-1 {
+			deps[a], deps[b] = deps[b], deps[a]
+		}
+		fm.lazyDeps = deps
+	}
+}
+
+func canDefer(p *provider, deferred map[*provider]bool, written map[typeCode]bool) bool {
+	if p.required || p.desired || p.wanted || p.isSynthetic {
+		return false
+	}
+	for _, tc := range p.flows[inputParams] {
+		if rm, found := p.downRmap[tc]; found {
+			tc = rm
+		}
+		if written[tc] {
+			return false
+		}
+	}
+	for _, tc := range p.flows[outputParams] {
+		if written[tc] {
+			return false
+		}
+	}
+	var consumed bool
+	for _, users := range p.d.usedByDetail[outputParams] {
+		for _, user := range users {
+			if !user.include {
+				continue
+			}
+			if !deferred[user] {
+				return false
+			}
+			consumed = true
+		}
+	}
+	return consumed
+}
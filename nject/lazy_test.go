@@ -0,0 +1,171 @@
+package nject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lazyS2 func() s2
+type lazyS3 func() (s3, error)
+
+func TestLazy(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var s2Calls, s3Calls int
+		var invoke func(s1, bool) (s2, s3)
+		require.NoError(t, Sequence("lazy",
+			Lazy(lazyS2(nil), func(s s1) s2 {
+				s2Calls++
+				return s2(s + "-2")
+			}),
+			Lazy(lazyS3(nil), func(l lazyS2) (s3, error) {
+				s3Calls++
+				return s3(l() + "-3"), nil
+			}),
+			func(use bool, l2 lazyS2, l3 lazyS3) (s2, s3) {
+				if !use {
+					return "", ""
+				}
+				x, err := l3()
+				assert.NoError(t, err)
+				return l2(), x
+			},
+		).Bind(&invoke, nil))
+
+		a, b := invoke("x", false)
+		assert.Equal(t, s2(""), a)
+		assert.Equal(t, s3(""), b)
+		assert.Equal(t, 0, s2Calls, "not used")
+		assert.Equal(t, 0, s3Calls, "not used")
+
+		a, b = invoke("y", true)
+		assert.Equal(t, s2("y-2"), a)
+		assert.Equal(t, s3("y-2-3"), b)
+		assert.Equal(t, 1, s2Calls, "once per invocation")
+		assert.Equal(t, 1, s3Calls)
+
+		a, b = invoke("z", true)
+		assert.Equal(t, s2("z-2"), a)
+		assert.Equal(t, s3("z-2-3"), b)
+		assert.Equal(t, 2, s2Calls)
+		assert.Equal(t, 2, s3Calls)
+	})
+}
+
+func TestLazyBadTypes(t *testing.T) {
+	assert.Panics(t, func() { Lazy(func() s2 { return "" }, func() s2 { return "" }) }, "unnamed")
+	assert.Panics(t, func() { Lazy(lazyS2(nil), func() s3 { return "" }) }, "mismatch")
+	assert.Panics(t, func() { Lazy(lazyS2(nil), "not a func") }, "not a func")
+}
+
+func TestLazyPanic(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var calls int
+		var invoke func()
+		require.NoError(t, Sequence("panic",
+			Lazy(lazyS2(nil), func() s2 {
+				calls++
+				panic("lazy failed")
+			}),
+			func(l lazyS2) {
+				assert.PanicsWithValue(t, "lazy failed", func() { l() })
+				assert.PanicsWithValue(t, "lazy failed", func() { l() }, "again")
+			},
+		).Bind(&invoke, nil))
+		invoke()
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestLazyDependenciesDeferred(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var s0Calls, s1Calls int
+		var invoke func(bool) s2
+		require.NoError(t, Sequence("deferred",
+			func() s0 {
+				s0Calls++
+				return "0"
+			},
+			func(s s0) s1 {
+				s1Calls++
+				return s1(s + "1")
+			},
+			Lazy(lazyS2(nil), func(s s1) s2 { return s2(s + "2") }),
+			func(use bool, l lazyS2) s2 {
+				if !use {
+					return ""
+				}
+				l()
+				return l()
+			},
+		).Bind(&invoke, nil))
+
+		assert.Equal(t, s2(""), invoke(false))
+		assert.Equal(t, 0, s0Calls, "not used")
+		assert.Equal(t, 0, s1Calls, "not used")
+
+		assert.Equal(t, s2("012"), invoke(true))
+		assert.Equal(t, 1, s0Calls, "once per invocation")
+		assert.Equal(t, 1, s1Calls, "once per invocation")
+	})
+}
+
+func TestLazyDependenciesNotDeferred(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var upstream int
+		var invoke func() s3
+		require.NoError(t, Sequence("shared",
+			func() s1 {
+				upstream++
+				return "up"
+			},
+			Lazy(lazyS2(nil), func(s s1) s2 { return s2(s) }),
+			func(s s1, l lazyS2) s3 { return s3(s) },
+		).Bind(&invoke, nil))
+		assert.Equal(t, s3("up"), invoke())
+		assert.Equal(t, 1, upstream, "s1 is also consumed by the final func")
+
+		upstream = 0
+		var invoke2 func() (s2, s3)
+		require.NoError(t, Sequence("replaced",
+			func() s0 { return "first" },
+			func(s s0) s1 {
+				upstream++
+				return s1(s)
+			},
+			func() s0 { return "second" },
+			Lazy(lazyS2(nil), func(s s1) s2 { return s2(s) }),
+			func(s s0, l lazyS2) (s2, s3) { return l(), s3(s) },
+		).Bind(&invoke2, nil))
+		a, b := invoke2()
+		assert.Equal(t, s2("first"), a, "s1 is made from the first s0")
+		assert.Equal(t, s3("second"), b)
+		assert.Equal(t, 1, upstream)
+	})
+}
+
+func TestLazyDependenciesDeferredStatic(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var upstream int
+		var invoke func(bool) s2
+		require.NoError(t, Sequence("static",
+			Cacheable(func() s1 {
+				upstream++
+				return "static"
+			}),
+			Cacheable(Lazy(lazyS2(nil), func(s s1) s2 { return s2(s) })),
+			func(use bool, l lazyS2) s2 {
+				if !use {
+					return ""
+				}
+				return l()
+			},
+		).Bind(&invoke, nil))
+		assert.Equal(t, s2(""), invoke(false))
+		assert.Equal(t, 0, upstream, "not used")
+		assert.Equal(t, s2("static"), invoke(true))
+		assert.Equal(t, s2("static"), invoke(true))
+		assert.Equal(t, 1, upstream, "once in the static chain")
+	})
+}
@@ -8,6 +8,8 @@ package nject
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 type interfaceMap map[typeCode]*interfaceMatchData
@@ -46,69 +48,245 @@ func (m interfaceMap) Copy() interfaceMap {
 	return copy
 }
 
-func aGreaterBInts(a []int, b []int) bool {
+// compareInts compares scores: it returns 1 if a is better than b,
+// -1 if b is better than a, and 0 if they are tied.
+func compareInts(a []int, b []int) int {
 	for i := 0; i < len(a) && i < len(b); i++ {
 		if a[i] > b[i] {
-			return true
+			return 1
 		}
 		if a[i] < b[i] {
-			return false
+			return -1
 		}
 	}
-	if len(a) < len(b) {
-		return false
+	switch {
+	case len(a) > len(b):
+		return 1
+	case len(a) < len(b):
+		return -1
+	}
+	return 0
+}
+
+// AmbiguousMatchError is why a Loose wrapper cannot be included when
+// more than one type could be used for one of the values returned by its
+// inner function and none of them is a better match than the others.
+// Other matches are not ambiguous: ties are broken by type name.  It can
+// be retrieved from the error returned by Bind with errors.As.
+type AmbiguousMatchError struct {
+	// Purpose is the kind of parameter, eg "input"
+	Purpose string
+	// Want is the type of the parameter
+	Want reflect.Type
+	// Candidates are the types that tied, sorted by their
+	// fully-qualified names
+	Candidates []reflect.Type
+}
+
+func (e AmbiguousMatchError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, t := range e.Candidates {
+		names[i] = t.String()
+	}
+	return fmt.Sprintf("has an ambiguous match for its %s parameter %s: it could be %s", e.Purpose, e.Want, strings.Join(names, " or "))
+}
+
+// qualifiedName is the name of t with the full package path so that
+// types from different packages with the same name sort consistently
+func qualifiedName(t reflect.Type) string {
+	switch {
+	case t.Name() != "" && t.PkgPath() != "":
+		return t.PkgPath() + "." + t.Name()
+	case t.Kind() == reflect.Ptr:
+		return "*" + qualifiedName(t.Elem())
 	}
-	return true
+	return t.String()
+}
+
+// matchCandidate is a type that could be used for a parameter
+type matchCandidate struct {
+	tc    typeCode
+	imd   *interfaceMatchData
+	plist []*provider
 }
 
-func (m interfaceMap) bestMatch(match typeCode, purpose string) (typeCode, []*provider, error) {
+// pickCandidate chooses the candidate with the best score.  Ties are
+// broken by the fully-qualified type name unless reportTies is set, in
+// which case candidates with the same score are ambiguous.
+func pickCandidate(match typeCode, purpose string, candidates []matchCandidate, scores [][]int, reportTies bool) (matchCandidate, error) {
+	var best []matchCandidate
+	var bestScore []int
+	for i, c := range candidates {
+		switch cmp := compareInts(scores[i], bestScore); {
+		case best == nil || cmp > 0:
+			best = []matchCandidate{c}
+			bestScore = scores[i]
+		case cmp == 0:
+			best = append(best, c)
+		}
+	}
+	if len(best) == 1 {
+		return best[0], nil
+	}
+	sort.Slice(best, func(i, j int) bool {
+		return qualifiedName(best[i].imd.typeCode.Type()) < qualifiedName(best[j].imd.typeCode.Type())
+	})
+	if !reportTies {
+		return best[0], nil
+	}
+	err := AmbiguousMatchError{
+		Purpose:    purpose,
+		Want:       match.Type(),
+		Candidates: make([]reflect.Type, len(best)),
+	}
+	for i, c := range best {
+		err.Candidates[i] = c.imd.typeCode.Type()
+	}
+	return best[0], err
+}
+
+// bestMatch finds the provided type to use for match.  consumerLoose
+// allows providers that are not annotated with Loose to be matched
+// against interfaces.  It is only set when matching the values returned
+// to a Loose wrapper and those matches are the only ones where a tie is
+// reported as an AmbiguousMatchError.
+func (m interfaceMap) bestMatch(match typeCode, purpose string, consumerLoose bool) (typeCode, []*provider, error) {
 	d, found := m[match]
 	if found {
 		d.consumed = true
 		return match, d.plist, nil
 	}
 	if match.Type().Kind() != reflect.Interface {
-		return match, nil, fmt.Errorf("has no match for its %s parameter %s", purpose, match)
+		tc, plist, err := m.convertibleMatch(match, purpose)
+		if err != nil || len(plist) > 0 {
+			return tc, plist, err
+		}
+		return match, nil, fmt.Errorf("has no match for its %s parameter %s%s", purpose, match, m.visibilityHint(match))
 	}
+	matchModule, _ := match.scope()
 	// What is the best match?
+	// (*) Not only provided by Default providers
 	// (*) Highest layer number
 	// (*) Same package path for source and destination
 	// (*) Highest method count
-	// (*) Lowest typeCode value
-	var best struct {
-		tc    typeCode
-		imd   *interfaceMatchData
-		score []int
-	}
-	score := func(tc typeCode, imd *interfaceMatchData) []int {
+	// (*) Lowest fully-qualified type name, except that ties are
+	//     ambiguous for values returned to a Loose wrapper
+	score := func(imd *interfaceMatchData) []int {
 		samePathScore := 0
 		if imd.typeCode.Type().PkgPath() == match.Type().PkgPath() {
 			samePathScore = 1
 		}
-		return []int{imd.layer, samePathScore, imd.typeCode.Type().NumMethod(), int(tc)}
+		notDefaultScore := 0
+		if len(notDefaultOnly(imd.typeCode, imd.plist)) > 0 {
+			notDefaultScore = 1
+		}
+		return []int{notDefaultScore, imd.layer, samePathScore, imd.typeCode.Type().NumMethod()}
 	}
+	var candidates []matchCandidate
+	var scores [][]int
 	for tc, imd := range m {
 		if !imd.typeCode.Type().Implements(match.Type()) {
 			continue
 		}
-		s := score(tc, imd)
-		if best.imd == nil || aGreaterBInts(s, best.score) {
-			best.tc = tc
-			best.imd = imd
-			best.score = s
+		if tcModule, _ := tc.scope(); tcModule != matchModule {
 			continue
 		}
+		candidates = append(candidates, matchCandidate{tc: tc, imd: imd, plist: imd.plist})
+		scores = append(scores, score(imd))
+	}
+	if len(candidates) == 0 {
+		return match, nil, fmt.Errorf("has no match for its %s parameter %s%s", purpose, match, m.visibilityHint(match))
+	}
+	best, err := pickCandidate(match, purpose, candidates, scores, consumerLoose)
+	if err != nil {
+		return match, nil, err
 	}
-	if best.imd == nil {
-		return match, nil, fmt.Errorf("has no match for its %s parameter %s", purpose, match)
+	loose := best.plist
+	if !consumerLoose {
+		loose = looseOnly(best.plist)
 	}
-	loose := looseOnly(best.imd.plist)
 	if len(loose) == 0 {
-		return match, nil, fmt.Errorf("has no match for its %s parameter %s (ignoring %s provided by %s)", purpose, match, best.imd.typeCode, best.imd.plist[0])
+		return match, nil, fmt.Errorf("has no match for its %s parameter %s (ignoring %s provided by %s)", purpose, match, best.imd.typeCode, best.plist[0])
 	}
 	return best.tc, loose, nil
 }
 
+// convertibleMatch looks for providers that are annotated with
+// ConvertibleTo, AutoConvert, or AdaptPointers and provide a type that
+// can be converted to match.  The closest such provider wins.
+func (m interfaceMap) convertibleMatch(match typeCode, purpose string) (typeCode, []*provider, error) {
+	matchModule, matchPublic := match.scope()
+	var candidates []matchCandidate
+	var scores [][]int
+	for tc, imd := range m {
+		if tcModule, _ := tc.scope(); tcModule != matchModule {
+			continue
+		}
+		var plist []*provider
+		for _, fm := range imd.plist {
+			if fm.canConvert(imd.typeCode.Type(), matchPublic) {
+				plist = append(plist, fm)
+			}
+		}
+		if len(plist) == 0 {
+			continue
+		}
+		notDefaultScore := 0
+		if len(notDefaultOnly(imd.typeCode, plist)) > 0 {
+			notDefaultScore = 1
+		}
+		candidates = append(candidates, matchCandidate{tc: tc, imd: imd, plist: plist})
+		scores = append(scores, []int{notDefaultScore, imd.layer})
+	}
+	if len(candidates) == 0 {
+		return match, nil, nil
+	}
+	best, err := pickCandidate(match, purpose, candidates, scores, false)
+	if err != nil {
+		return match, nil, err
+	}
+	best.imd.consumed = true
+	return best.tc, best.plist, nil
+}
+
+// isConversion is true when a parameter of type in is matched to
+// found by converting rather than by an interface match
+func isConversion(in typeCode, found typeCode) bool {
+	return found != in && in.Type().Kind() != reflect.Interface
+}
+
+// canConvert is true if fm is annotated to allow its values of type
+// from to be converted to the type to.
+func (fm *provider) canConvert(from reflect.Type, to typeCode) bool {
+	if !fm.autoConvert && len(fm.convertTo) == 0 && !fm.adaptPointers {
+		return false
+	}
+	toType := to.Type()
+	if from == toType || from.Kind() == reflect.Interface {
+		return false
+	}
+	if fm.adaptPointers && differByPointer(from, toType) {
+		return true
+	}
+	if !from.ConvertibleTo(toType) {
+		return false
+	}
+	if fm.convertTo[to] {
+		return true
+	}
+	return fm.autoConvert && from.Kind() == toType.Kind()
+}
+
+func notDefaultOnly(tc typeCode, plist []*provider) []*provider {
+	notDefault := make([]*provider, 0, len(plist))
+	for _, fm := range plist {
+		if !fm.isDefaultFor(tc) {
+			notDefault = append(notDefault, fm)
+		}
+	}
+	return notDefault
+}
+
 func looseOnly(plist []*provider) []*provider {
 	loose := make([]*provider, 0, len(plist))
 	for _, fm := range plist {
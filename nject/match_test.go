@@ -1,11 +1,14 @@
 package nject
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testI int
@@ -65,7 +68,7 @@ func TestBestMatch(t *testing.T) {
 				for tc, d := range m {
 					t.Logf("\tm[%s] = %s (%s) %d", tc.Type(), d.name, d.typeCode.Type(), d.layer)
 				}
-				got, _, err := m.bestMatch(getTypeCode(test.Find), "searching for "+test.Name)
+				got, _, err := m.bestMatch(getTypeCode(test.Find), "searching for "+test.Name, false)
 				assert.NoError(t, err)
 				assert.Equal(t, test.Want.String(), got.Type().String(), test.Name)
 			}
@@ -77,3 +80,104 @@ func TestBestMatch(t *testing.T) {
 		}
 	})
 }
+
+type ambiguousA int
+type ambiguousB int
+
+func (ambiguousA) String() string { return "a" }
+func (ambiguousB) String() string { return "b" }
+
+func TestTiedMatchByName(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got string
+		MustRun("tied",
+			Loose(func() (ambiguousB, ambiguousA) { return 2, 1 }),
+			func(s fmt.Stringer) { got = s.String() },
+		)
+		assert.Equal(t, "a", got, "nject.ambiguousA sorts before nject.ambiguousB")
+	})
+}
+
+func TestAmbiguousMatch(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() string
+		err := Sequence("ambiguous",
+			Required(Loose(func(inner func() fmt.Stringer) string { return inner().String() })),
+			func() (ambiguousA, ambiguousB) { return 1, 2 },
+		).Bind(&invoke, nil)
+		require.Error(t, err)
+		var ame AmbiguousMatchError
+		require.True(t, errors.As(err, &ame), "error %v", err)
+		assert.Equal(t, "expected return", ame.Purpose)
+		assert.Equal(t, reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), ame.Want)
+		assert.Equal(t, []reflect.Type{reflect.TypeOf(ambiguousA(0)), reflect.TypeOf(ambiguousB(0))}, ame.Candidates)
+	})
+}
+
+func TestAmbiguousMatchClosestWins(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var got string
+		MustRun("closest",
+			Loose(func() ambiguousA { return 1 }),
+			Loose(func() ambiguousB { return 2 }),
+			func(s fmt.Stringer) { got = s.String() },
+		)
+		assert.Equal(t, "b", got)
+	})
+}
+
+func TestLooseReturnedValues(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() string
+		require.NoError(t, Sequence("loose returned",
+			Loose(func(inner func() fmt.Stringer) string { return inner().String() }),
+			func() ambiguousA { return 1 },
+		).Bind(&invoke, nil))
+		assert.Equal(t, "a", invoke())
+
+		err := Sequence("not loose",
+			func(inner func() fmt.Stringer) string { return inner().String() },
+			func() ambiguousA { return 1 },
+		).Bind(&invoke, nil)
+		assert.Error(t, err)
+	})
+}
+
+type wrappedStringer struct{ fmt.Stringer }
+
+func (w wrappedStringer) String() string { return "w:" + w.Stringer.String() }
+
+func TestInterfaceReturnedThroughWrapper(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called int
+		wrapper := func(inner func() fmt.Stringer) fmt.Stringer {
+			called++
+			return wrappedStringer{inner()}
+		}
+
+		var invokeStringer func() fmt.Stringer
+		require.NoError(t, Sequence("same interface",
+			wrapper,
+			func() fmt.Stringer { return ambiguousA(1) },
+		).Bind(&invokeStringer, nil))
+		assert.Equal(t, "w:a", invokeStringer().String())
+		assert.Equal(t, 1, called)
+
+		var invokeA func() ambiguousA
+		require.NoError(t, Sequence("not consumed",
+			Loose(wrapper),
+			func() ambiguousA { return 1 },
+		).Bind(&invokeA, nil))
+		assert.Equal(t, ambiguousA(1), invokeA())
+		assert.Equal(t, 1, called, "wrapper is not needed")
+
+		var invokeString func() string
+		require.NoError(t, Sequence("loose",
+			Loose(func(inner func() fmt.Stringer) string { return inner().String() }),
+			Loose(wrapper),
+			func() ambiguousA { return 1 },
+		).Bind(&invokeString, nil))
+		assert.Equal(t, "w:a", invokeString())
+		assert.Equal(t, 2, called)
+	})
+}
@@ -0,0 +1,102 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MethodFilter is used by Methods to select and annotate the methods
+// that become providers.  It is called with the method name and the
+// method value.  Return nil to leave the method out.  Otherwise return
+// fn, or fn with annotations:
+//
+//	func(name string, fn interface{}) interface{} {
+//		switch name {
+//		case "String":
+//			return nil
+//		case "LoadConfig":
+//			return nject.Cacheable(fn)
+//		}
+//		return fn
+//	}
+type MethodFilter func(name string, fn interface{}) interface{}
+
+// Methods creates a Collection from the exported methods of obj.  Each
+// method becomes a provider named Type.Method (eg "Service.LoadUser").
+// The methods are in alphabetical order.  Use MethodsInOrder if the
+// order matters.
+//
+// If filter is nil, all exported methods are included.  Remember that
+// the methods of a pointer type include the methods of the type it
+// points to.
+func Methods(obj interface{}, filter MethodFilter) *Collection {
+	v, typeName := methodReceiver(obj)
+	t := v.Type()
+	names := make([]string, t.NumMethod())
+	for i := range names {
+		names[i] = t.Method(i).Name
+	}
+	return methodCollection(v, typeName, filter, names)
+}
+
+// MethodsInOrder is like Methods except that only the methods that
+// are listed in names are included and they are in the order given.
+// MethodsInOrder panics if obj does not have one of the methods.
+func MethodsInOrder(obj interface{}, filter MethodFilter, names ...string) *Collection {
+	v, typeName := methodReceiver(obj)
+	for _, name := range names {
+		if !v.MethodByName(name).IsValid() {
+			panic(fmt.Sprintf("%s does not have an exported method %s", v.Type(), name))
+		}
+	}
+	return methodCollection(v, typeName, filter, names)
+}
+
+func methodReceiver(obj interface{}) (reflect.Value, string) {
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() {
+		panic("Methods requires an object, not nil")
+	}
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	typeName := t.Name()
+	if typeName == "" {
+		typeName = t.String()
+	}
+	return v, typeName
+}
+
+func methodCollection(v reflect.Value, typeName string, filter MethodFilter, names []string) *Collection {
+	providers := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		var fn interface{} = v.MethodByName(name).Interface()
+		location := methodLocation(v.Type(), name)
+		if filter != nil {
+			fn = filter(name, fn)
+			if fn == nil {
+				continue
+			}
+		}
+		providers = append(providers, Provide(typeName+"."+name, fn).modify(func(fm *provider) {
+			fm.location = location
+		}))
+	}
+	return Sequence(typeName, providers...)
+}
+
+// methodLocation returns the source location of the named method of t.
+// The methods of a pointer type that have value receivers are wrappers
+// generated by the compiler so the location is taken from the type that
+// the method is declared on.
+func methodLocation(t reflect.Type, name string) string {
+	for t.Kind() == reflect.Ptr {
+		if _, found := t.Elem().MethodByName(name); !found {
+			break
+		}
+		t = t.Elem()
+	}
+	method, _ := t.MethodByName(name)
+	return pcLocation(method.Func.Pointer())
+}
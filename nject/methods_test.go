@@ -0,0 +1,75 @@
+package nject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type methodService struct {
+	prefix string
+}
+
+func (m *methodService) LoadUser(s s1) s2  { return s2(m.prefix + string(s)) }
+func (m *methodService) Authorize(s s2) s3 { return s3(s + "!") }
+func (m methodService) Config() s1         { return s1(m.prefix) }
+func (m methodService) String() string     { return m.prefix }
+
+func TestMethods(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		svc := &methodService{prefix: "x-"}
+		c := Methods(svc, func(name string, fn interface{}) interface{} {
+			switch name {
+			case "String", "LoadUser":
+				return nil
+			case "Config":
+				return Cacheable(fn)
+			}
+			return fn
+		})
+		var invoke func(s2) (s1, s3, *Debugging)
+		require.NoError(t, Sequence("test", c, func(a s1, b s3, d *Debugging) (s1, s3, *Debugging) { return a, b, d }).Bind(&invoke, nil))
+		a, b, d := invoke("bob")
+		assert.Equal(t, s1("x-"), a)
+		assert.Equal(t, s3("bob!"), b)
+		assert.Contains(t, d.NamesIncluded, "methodService.Config")
+		assert.Contains(t, d.NamesIncluded, "methodService.Authorize")
+		assert.NotContains(t, d.NamesIncluded, "methodService.LoadUser")
+		assert.Contains(t, withoutLocations(d.Included), "static static-injector: test/methodService/methodService.Config [func() nject.s1]")
+
+		var names []string
+		_ = Methods(svc, func(name string, fn interface{}) interface{} {
+			names = append(names, name)
+			return nil
+		})
+		assert.Equal(t, []string{"Authorize", "Config", "LoadUser", "String"}, names, "alphabetical")
+
+		var invoke2 func() s3
+		require.NoError(t, Sequence("ordered",
+			MethodsInOrder(svc, nil, "Config", "LoadUser", "Authorize"),
+			func(s s3) s3 { return s },
+		).Bind(&invoke2, nil))
+		assert.Equal(t, s3("x-x-!"), invoke2())
+
+		assert.Panics(t, func() { MethodsInOrder(svc, nil, "Missing") })
+		assert.Panics(t, func() { Methods(nil, nil) })
+	})
+}
+
+func TestMethodLocations(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() *Debugging
+		require.NoError(t, Sequence("locations",
+			MethodsInOrder(&methodService{}, nil, "Config", "LoadUser"),
+			func(_ s2, d *Debugging) *Debugging { return d },
+		).Bind(&invoke, nil))
+		d := invoke()
+		require.Len(t, d.Providers, 5)
+		assert.Equal(t, "locations/methodService/methodService.Config", d.Providers[2].Path)
+		assert.Regexp(t, `^nject/methods_test\.go:\d+$`, d.Providers[2].Location, "value receiver through a pointer")
+		assert.Equal(t, "locations/methodService/methodService.LoadUser", d.Providers[3].Path)
+		assert.Regexp(t, `^nject/methods_test\.go:\d+$`, d.Providers[3].Location, "pointer receiver")
+		assert.Contains(t, d.Included[2], "methodService.Config [func() nject.s1] at nject/methods_test.go:")
+	})
+}
@@ -0,0 +1,142 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// module is the scope for the types that are private to a Module
+type module struct {
+	name    string
+	exports map[typeCode]bool
+	lock    sync.Mutex
+	codes   map[typeCode]typeCode // public -> scoped
+}
+
+type scopeData struct {
+	public typeCode
+	module *module
+}
+
+// scopes maps scoped typeCodes to scopeData.  It is written once
+// per scoped typeCode and read while matching so it is a sync.Map
+// rather than being protected by lock.
+var scopes sync.Map
+
+// Module creates a Collection whose types are private except for the
+// types listed in exports.  Providers outside the module can only see
+// the exported types.  A private type that is provided inside the module
+// can only be consumed inside the module.  A private type that is consumed
+// inside the module can only be provided inside the module: a provider of
+// the same type outside the module neither satisfies nor shadows it.
+//
+// Modules can be nested.  A type that is exported from an inner module is
+// visible in the outer module but is private to the outer module unless
+// the outer module exports it too.
+//
+// The error type and nject's own types (like *Debugging) are always
+// exported.
+//
+// Each Module keeps track of its private types for as long as the program
+// runs, so create modules once, like other Collections, rather than on
+// every request.
+func Module(name string, exports []reflect.Type, providers ...interface{}) *Collection {
+	m := &module{
+		name:    name,
+		exports: make(map[typeCode]bool),
+		codes:   make(map[typeCode]typeCode),
+	}
+	for _, t := range exports {
+		m.exports[getTypeCode(t)] = true
+	}
+	c := newCollection(name, providers...)
+	for i, fm := range c.contents {
+		fm = fm.copy()
+		fm.modules = append(append(make([]*module, 0, len(fm.modules)+1), fm.modules...), m)
+		c.contents[i] = fm
+	}
+	return c
+}
+
+var alwaysExported = map[typeCode]bool{
+	noTypeCode:                                true,
+	presenceTypeCode:                          true,
+	getTypeCode(errorType):                    true,
+	getTypeCode(terminalErrorType):            true,
+	getTypeCode(reflect.TypeOf(&Debugging{})): true,
+	invokeTraceTypeCode:                       true,
+	getTypeCode(retryAttemptsType):            true,
+	generationTypeCode:                        true,
+}
+
+// scopeFlows replaces the private types in fm's flows with
+// types that are scoped to the module that keeps them private.
+func (fm *provider) scopeFlows() {
+	if len(fm.modules) == 0 {
+		return
+	}
+	for param, flow := range fm.flows {
+		scoped := make([]typeCode, len(flow))
+		for i, tc := range flow {
+			scoped[i] = tc
+			if alwaysExported[tc] {
+				continue
+			}
+			// modules are innermost first: the first module that
+			// does not export the type is the one it is private to.
+			for _, m := range fm.modules {
+				if !m.exports[tc] {
+					scoped[i] = tc.scoped(m)
+					break
+				}
+			}
+		}
+		fm.flows[param] = scoped
+	}
+}
+
+// scoped returns a typeCode for the same Go type that can
+// only match other providers in the same module.
+func (tc typeCode) scoped(m *module) typeCode {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if sc, found := m.codes[tc]; found {
+		return sc
+	}
+	lock.Lock()
+	typeCounter++
+	sc := typeCode(typeCounter)
+	reverseMap[sc] = reverseMap[tc]
+	lock.Unlock()
+	m.codes[tc] = sc
+	scopes.Store(sc, scopeData{public: tc, module: m})
+	return sc
+}
+
+// scope returns the module that tc is private to and the
+// typeCode that would be used outside the module.  The module
+// is nil if tc is not private.
+func (tc typeCode) scope() (*module, typeCode) {
+	if sd, found := scopes.Load(tc); found {
+		return sd.(scopeData).module, sd.(scopeData).public
+	}
+	return nil, tc
+}
+
+// visibilityHint explains why a type that is provided in a module
+// cannot be matched from outside of it.
+func (m interfaceMap) visibilityHint(match typeCode) string {
+	matchModule, matchPublic := match.scope()
+	for tc, imd := range m {
+		tcModule, tcPublic := tc.scope()
+		if tcModule == matchModule || tcPublic != matchPublic {
+			continue
+		}
+		if tcModule != nil {
+			return fmt.Sprintf(" (%s is provided by %s but it is private to module %s)", matchPublic, imd.plist[0], tcModule.name)
+		}
+		return fmt.Sprintf(" (%s is provided by %s but is not visible inside module %s)", matchPublic, imd.plist[0], matchModule.name)
+	}
+	return ""
+}
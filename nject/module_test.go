@@ -0,0 +1,95 @@
+package nject
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		auth := Module("auth", []reflect.Type{reflect.TypeOf(s2(""))},
+			func() s1 { return "private" },
+			func(s s1) s2 { return s2("from " + s) },
+		)
+
+		// the private s1 does not shadow the outer s1
+		var invoke func() (s1, s2)
+		require.NoError(t, Sequence("outer",
+			func() s1 { return "outer" },
+			auth,
+			func(a s1, b s2) (s1, s2) { return a, b },
+		).Bind(&invoke, nil))
+		a, b := invoke()
+		assert.Equal(t, s1("outer"), a)
+		assert.Equal(t, s2("from private"), b)
+
+		// the private s1 is not visible outside
+		var invoke2 func() s1
+		err := Sequence("leak",
+			auth,
+			func(a s1) s1 { return a },
+		).Bind(&invoke2, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "private to module auth")
+		}
+
+		// an outer s1 does not satisfy a private s1
+		consumer := Module("consumer", []reflect.Type{reflect.TypeOf(s2(""))},
+			func(s s1) s2 { return s2(s) },
+		)
+		var invoke3 func() s2
+		err = Sequence("satisfy",
+			func() s1 { return "outer" },
+			consumer,
+			func(b s2) s2 { return b },
+		).Bind(&invoke3, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "not visible inside module consumer")
+		}
+	})
+}
+
+func TestNestedModules(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		inner := Module("inner", []reflect.Type{reflect.TypeOf(s2(""))},
+			func() s1 { return "inner" },
+			func(s s1) s2 { return s2(s) },
+		)
+		outer := Module("outer", []reflect.Type{reflect.TypeOf(s3(""))},
+			inner,
+			func(s s2) s3 { return s3(s + "!") },
+		)
+		var invoke func() s3
+		require.NoError(t, Sequence("nested", outer, func(s s3) s3 { return s }).Bind(&invoke, nil))
+		assert.Equal(t, s3("inner!"), invoke())
+
+		var invoke2 func() s2
+		err := Sequence("nested-leak", outer, func(s s2) s2 { return s }).Bind(&invoke2, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "private to module outer")
+		}
+	})
+}
+
+func TestModuleConcurrentBinds(t *testing.T) {
+	auth := Module("auth", []reflect.Type{reflect.TypeOf(s2(""))},
+		func() s1 { return "private" },
+		func(s s1) s2 { return s2("from " + s) },
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var invoke func() s2
+			if assert.NoError(t, Sequence("concurrent", auth, func(b s2) s2 { return b }).Bind(&invoke, nil)) {
+				assert.Equal(t, s2("from private"), invoke())
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -3,18 +3,24 @@ package nject
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"sync/atomic"
+	"time"
 )
 
 var idCounter int32
 
 // provider is an annotated reference to a provider
 type provider struct {
-	origin string
-	index  int
-	fn     interface{}
-	id     int32
+	origin   string
+	index    int
+	fn       interface{}
+	id       int32
+	path     []string // names of the enclosing collections, outermost first
+	location string   // source file:line of fn
 
 	// user annotations
 	cacheable           bool
@@ -27,6 +33,17 @@ type provider struct {
 	notCacheable        bool
 	mustConsume         bool
 	consumptionOptional bool
+	overrides           bool
+	optionalInputs      bool
+	isDefault           bool
+	timeout             time.Duration // wrappers annotated with Timeout
+	timeoutInjector     bool          // injectors annotated with Timeout
+	defaultTypes        map[typeCode]bool
+	autoConvert         bool
+	convertTo           map[typeCode]bool
+	adaptPointers       bool
+	lazy                *lazyProvider
+	modules             []*module // innermost first
 
 	// added by characterize
 	memoized    bool
@@ -49,8 +66,11 @@ type provider struct {
 	chainPosition              int
 	mustZeroIfRemainderSkipped []typeCode
 	mustZeroIfInnerNotCalled   []typeCode
+	suppressedOutputs          map[typeCode]bool
 	upVmapCount                int
 	downVmapCount              int
+	deferred                   bool        // run by a Lazy provider instead of by the chain
+	lazyDeps                   []*provider // providers deferred for this Lazy provider, in chain order
 
 	wrapWrapper          func(valueCollection, func(valueCollection) valueCollection) valueCollection // added in generate
 	wrapStaticInjector   func(valueCollection) error                                                  // added in generate
@@ -68,6 +88,8 @@ func (fm *provider) copy() *provider {
 		index:               fm.index,
 		fn:                  fm.fn,
 		id:                  fm.id,
+		path:                fm.path,
+		location:            fm.location,
 		cacheable:           fm.cacheable,
 		mustCache:           fm.mustCache,
 		required:            fm.required,
@@ -78,6 +100,17 @@ func (fm *provider) copy() *provider {
 		desired:             fm.desired,
 		mustConsume:         fm.mustConsume,
 		consumptionOptional: fm.consumptionOptional,
+		overrides:           fm.overrides,
+		optionalInputs:      fm.optionalInputs,
+		isDefault:           fm.isDefault,
+		timeout:             fm.timeout,
+		timeoutInjector:     fm.timeoutInjector,
+		defaultTypes:        fm.defaultTypes,
+		autoConvert:         fm.autoConvert,
+		convertTo:           fm.convertTo,
+		adaptPointers:       fm.adaptPointers,
+		lazy:                fm.lazy,
+		modules:             fm.modules,
 		notCacheable:        fm.notCacheable,
 		class:               fm.class,
 		group:               fm.group,
@@ -119,13 +152,40 @@ func newProvider(fn interface{}, index int, origin string) *provider {
 		panic("Cannot turn Collection into a function")
 	}
 	return &provider{
-		origin: origin,
-		index:  index,
-		fn:     fn,
-		id:     atomic.AddInt32(&idCounter, 1),
+		origin:   origin,
+		index:    index,
+		fn:       fn,
+		id:       atomic.AddInt32(&idCounter, 1),
+		location: funcLocation(fn),
 	}
 }
 
+// funcLocation returns the source file and line of a function
+// as dir/file.go:line or "" if fn is not a function.  Functions
+// created by reflect and nject's own providers have no location.
+func funcLocation(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	return pcLocation(v.Pointer())
+}
+
+func pcLocation(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil || strings.HasPrefix(f.Name(), "reflect.") {
+		return ""
+	}
+	file, line := f.FileLine(f.Entry())
+	if file == "" || file == "<autogenerated>" {
+		return ""
+	}
+	if strings.HasPrefix(f.Name(), njectPkgPath+".") && !strings.HasSuffix(file, "_test.go") {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
+}
+
 func (fm *provider) String() string {
 	var t string
 	if fm.fn == nil {
@@ -137,10 +197,39 @@ func (fm *provider) String() string {
 	if fm.class != "" {
 		class = string(fm.class) + ": "
 	}
+	location := ""
+	if fm.location != "" {
+		location = " at " + fm.location
+	}
+	return fmt.Sprintf("%s%s [%s]%s", class, fm.fullName(), t, location)
+}
+
+// fullName is the name prefixed by the names of the collections
+// that contain the provider, eg "service/auth/loadUser".
+func (fm *provider) fullName() string {
+	path := fm.path
+	if len(path) > 0 && path[len(path)-1] == fm.origin {
+		path = path[:len(path)-1]
+	}
+	if len(path) == 0 {
+		return fm.shortName()
+	}
+	return strings.Join(path, "/") + "/" + fm.shortName()
+}
+
+// inCollection returns a copy of fm that is inside the named collection
+func (fm *provider) inCollection(name string) *provider {
+	c := fm.copy()
+	c.path = append([]string{name}, fm.path...)
+	return c
+}
+
+// shortName is the name without the type signature
+func (fm *provider) shortName() string {
 	if fm.index >= 0 {
-		return fmt.Sprintf("%s%s(%d) [%s]", class, fm.origin, fm.index, t)
+		return fmt.Sprintf("%s(%d)", fm.origin, fm.index)
 	}
-	return fmt.Sprintf("%s%s [%s]", class, fm.origin, t)
+	return fm.origin
 }
 
 func (fm *provider) errorf(format string, args ...interface{}) error {
@@ -150,9 +239,9 @@ func (fm *provider) errorf(format string, args ...interface{}) error {
 // This characterizes all the providers and flattens the collection into
 // a couple of lists of providers: providers that run before invoke; and
 // providers that run after invoke.
-func (c Collection) characterizeAndFlatten(nonStaticTypes map[typeCode]bool) ([]*provider, []*provider, error) {
-	debugln("BEGIN characterizeAndFlatten")
-	defer debugln("END characterizeAndFlatten")
+func (c Collection) characterizeAndFlatten(nonStaticTypes map[typeCode]bool, dbg *debugSink) ([]*provider, []*provider, error) {
+	dbg.debugln("BEGIN characterizeAndFlatten")
+	defer dbg.debugln("END characterizeAndFlatten")
 
 	afterInit := make([]*provider, 0, len(c.contents))
 	afterInvoke := make([]*provider, 0, len(c.contents))
@@ -207,18 +296,22 @@ func newCollection(name string, funcs ...interface{}) *Collection {
 		switch v := fn.(type) {
 		case *Collection:
 			if v != nil {
-				contents = append(contents, v.contents...)
+				for _, fm := range v.contents {
+					contents = append(contents, fm.inCollection(name))
+				}
 			}
 		case Collection:
-			contents = append(contents, v.contents...)
+			for _, fm := range v.contents {
+				contents = append(contents, fm.inCollection(name))
+			}
 		case *provider:
 			if v != nil {
-				contents = append(contents, v.renameIfEmpty(i, name))
+				contents = append(contents, v.renameIfEmpty(i, name).inCollection(name))
 			}
 		case provider:
-			contents = append(contents, v.renameIfEmpty(i, name))
+			contents = append(contents, v.renameIfEmpty(i, name).inCollection(name))
 		default:
-			contents = append(contents, newProvider(fn, i, name))
+			contents = append(contents, newProvider(fn, i, name).inCollection(name))
 		}
 	}
 	return &Collection{
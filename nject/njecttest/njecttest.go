@@ -0,0 +1,175 @@
+// Package njecttest has helpers for testing nject provider chains:
+// assertions about which providers are included, a Recorder that
+// counts provider calls, and Override to inject fakes.
+package njecttest
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/BlueOwlOpenSource/nject/nject"
+)
+
+// TestingT is the part of testing.TB that the assertions use
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertIncluded checks that each of the named providers is included
+// when c is bound with invoke and init.  Names are matched as for
+// nject.Collection.Explain.  Invoke and init are pointers to functions,
+// as for Bind, but they are not modified.  Init may be nil.  When a
+// provider is not included, the explanation of why is part of the failure.
+func AssertIncluded(t TestingT, c *nject.Collection, invoke interface{}, init interface{}, names ...string) bool {
+	t.Helper()
+	return assertInclusion(t, c, invoke, init, true, names)
+}
+
+// AssertExcluded checks that none of the named providers are included
+// when c is bound with invoke and init.  Invoke and init are not modified.
+// Init may be nil.
+func AssertExcluded(t TestingT, c *nject.Collection, invoke interface{}, init interface{}, names ...string) bool {
+	t.Helper()
+	return assertInclusion(t, c, invoke, init, false, names)
+}
+
+func assertInclusion(t TestingT, c *nject.Collection, invoke interface{}, init interface{}, included bool, names []string) bool {
+	t.Helper()
+	ok := true
+	for _, name := range names {
+		e, err := c.Explain(invoke, init, name)
+		if err != nil {
+			t.Errorf("cannot explain %s: %s", name, err)
+			ok = false
+			continue
+		}
+		if e.Included != included {
+			t.Errorf("%s", e)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Recorder keeps track of calls to providers that it wraps.  It replaces
+// the common pattern of incrementing a map in each provider:
+//
+//	r := njecttest.NewRecorder()
+//	c := nject.Sequence("test",
+//		r.Func("loadUser", loadUser),
+//		r.Func("handler", handler),
+//	)
+//	...
+//	njecttest.AssertCalledOrder(t, r, "loadUser", "handler")
+//
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	calls  []string
+	counts map[string]int
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		counts: make(map[string]int),
+	}
+}
+
+// Func wraps fn so that each call is recorded under name.  The
+// wrapped function has the same type as fn and is named name
+// (see nject.Provide).  Func panics if fn is not a function.  Annotate
+// the result, not fn: nject.Cacheable(r.Func("config", loadConfig)).
+func (r *Recorder) Func(name string, fn interface{}) nject.Provider {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("Recorder.Func requires a function, not %T", fn))
+	}
+	w := reflect.MakeFunc(v.Type(), func(in []reflect.Value) []reflect.Value {
+		r.record(name)
+		if v.Type().IsVariadic() {
+			return v.CallSlice(in)
+		}
+		return v.Call(in)
+	})
+	return nject.Provide(name, w.Interface())
+}
+
+func (r *Recorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, name)
+	r.counts[name]++
+}
+
+// Count returns the number of times that the named provider was called
+func (r *Recorder) Count(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[name]
+}
+
+// Calls returns the names of the providers in the order they were called
+func (r *Recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// Reset forgets all calls
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+	r.counts = make(map[string]int)
+}
+
+// AssertCalledOrder checks that the named providers were called in
+// the order given.  Other calls may come between them.
+func AssertCalledOrder(t TestingT, r *Recorder, names ...string) bool {
+	t.Helper()
+	calls := r.Calls()
+	i := 0
+	for _, call := range calls {
+		if i < len(names) && call == names[i] {
+			i++
+		}
+	}
+	if i < len(names) {
+		t.Errorf("expected calls in order %v but %s was not called after %v; calls were %v", names, names[i], names[:i], calls)
+		return false
+	}
+	return true
+}
+
+// Override creates a Collection that provides value as type typ
+// in place of the providers of typ in c.  The fake is at the head
+// of the new Collection and the providers of typ in c are annotated
+// with nject.DefaultFor so the fake is used no matter where in c they
+// are.  The other outputs of those providers are not affected.
+// Override panics if value cannot be assigned to typ.
+func Override(c *nject.Collection, typ reflect.Type, value interface{}) *nject.Collection {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			v = reflect.Zero(typ)
+		default:
+			panic(fmt.Sprintf("cannot override %s with nil", typ))
+		}
+	}
+	if !v.Type().AssignableTo(typ) {
+		panic(fmt.Sprintf("cannot override %s with a %s", typ, v.Type()))
+	}
+	fake := reflect.MakeFunc(reflect.FuncOf(nil, []reflect.Type{typ}, false), func([]reflect.Value) []reflect.Value {
+		out := reflect.New(typ).Elem()
+		out.Set(v)
+		return []reflect.Value{out}
+	})
+	name := "override " + typ.String()
+	return nject.Sequence(name,
+		nject.Provide(name, fake.Interface()),
+		nject.DefaultFor([]reflect.Type{typ}, c))
+}
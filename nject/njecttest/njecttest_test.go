@@ -0,0 +1,116 @@
+package njecttest_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/BlueOwlOpenSource/nject/nject"
+	"github.com/BlueOwlOpenSource/nject/nject/njecttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userID string
+
+func (u userID) String() string { return string(u) }
+
+type user string
+type clock func() string
+
+type fakeT struct {
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertInclusion(t *testing.T) {
+	c := nject.Sequence("test",
+		nject.Provide("loadUser", func(id userID) user { return user("u-" + id) }),
+		nject.Provide("clock", func() clock { return func() string { return "now" } }),
+		nject.Provide("handler", func(u user) string { return string(u) }),
+	)
+	var invoke func(userID) string
+	njecttest.AssertIncluded(t, c, &invoke, nil, "loadUser", "handler")
+	njecttest.AssertExcluded(t, c, &invoke, nil, "clock")
+
+	f := &fakeT{}
+	assert.False(t, njecttest.AssertIncluded(f, c, &invoke, nil, "clock", "missing"))
+	require.Len(t, f.errors, 2)
+	assert.Contains(t, f.errors[0], "clock is excluded")
+	assert.Contains(t, f.errors[1], "missing")
+
+	f = &fakeT{}
+	assert.False(t, njecttest.AssertExcluded(f, c, &invoke, nil, "loadUser"))
+	require.Len(t, f.errors, 1)
+	assert.Contains(t, f.errors[0], "loadUser is included")
+}
+
+func TestAssertInclusionStatic(t *testing.T) {
+	type config string
+	c := nject.Sequence("static",
+		nject.Provide("config", func(name string) config { return config("config for " + name) }),
+		nject.Provide("handler", func(cfg config, id userID) string { return string(cfg) + " " + string(id) }),
+	)
+	var invoke func(userID) string
+	var init func(string)
+	njecttest.AssertIncluded(t, c, &invoke, &init, "config", "handler")
+
+	f := &fakeT{}
+	assert.False(t, njecttest.AssertIncluded(f, c, &invoke, nil, "config"))
+	require.Len(t, f.errors, 1)
+}
+
+func TestRecorder(t *testing.T) {
+	r := njecttest.NewRecorder()
+	c := nject.Sequence("test",
+		r.Func("wrap", func(inner func() string) string { return "[" + inner() + "]" }),
+		r.Func("loadUser", func(id userID) user { return user("u-" + id) }),
+		r.Func("clock", func() clock { return func() string { return "now" } }),
+		r.Func("handler", func(u user) string { return string(u) }),
+	)
+	var invoke func(userID) string
+	require.NoError(t, c.Bind(&invoke, nil))
+	assert.Equal(t, "[u-1]", invoke("1"))
+	assert.Equal(t, "[u-2]", invoke("2"))
+	assert.Equal(t, 2, r.Count("loadUser"))
+	assert.Equal(t, 0, r.Count("clock"))
+	njecttest.AssertCalledOrder(t, r, "wrap", "loadUser", "handler", "loadUser")
+
+	f := &fakeT{}
+	assert.False(t, njecttest.AssertCalledOrder(f, r, "handler", "wrap", "clock"))
+	require.Len(t, f.errors, 1)
+	assert.Contains(t, f.errors[0], "clock was not called after [handler wrap]")
+
+	r.Reset()
+	assert.Empty(t, r.Calls())
+}
+
+func TestOverride(t *testing.T) {
+	c := nject.Sequence("app",
+		nject.Provide("clock", func() clock { return func() string { return "real" } }),
+		nject.Provide("handler", func(c clock, id userID) string { return c() + " " + string(id) }),
+	)
+	var invoke func(userID) string
+	require.NoError(t, njecttest.Override(c, reflect.TypeOf(clock(nil)), clock(func() string { return "fake" })).Bind(&invoke, nil))
+	assert.Equal(t, "fake 1", invoke("1"))
+
+	require.NoError(t, c.Bind(&invoke, nil))
+	assert.Equal(t, "real 1", invoke("1"))
+
+	// the fake can be a concrete value for an interface type
+	var name string
+	require.NoError(t, nject.Run("interface",
+		njecttest.Override(nject.Sequence("s",
+			func() fmt.Stringer { return nil },
+			func(s fmt.Stringer) { name = s.String() },
+		), reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), userID("fake"))))
+	assert.Equal(t, "fake", name)
+
+	assert.Panics(t, func() {
+		njecttest.Override(c, reflect.TypeOf(clock(nil)), "not a clock")
+	})
+}
@@ -0,0 +1,54 @@
+package nject
+
+import (
+	"reflect"
+)
+
+// Presence can be taken as an input by providers that are annotated
+// with OptionalInputs.  It reports which of the provider's inputs are
+// supplied by the provider chain.  Presence is figured out by Bind:
+// it does not change from one invocation to the next.
+type Presence struct {
+	missing map[reflect.Type]bool
+}
+
+var presenceTypeCode = getTypeCode(Presence{})
+
+// Present returns true if the input whose type is pointed to by ptr
+// has a provider.  Pass a nil pointer of the right type:
+//
+//	presence.Present((*Principal)(nil))
+func (p Presence) Present(ptr interface{}) bool {
+	return p.PresentType(reflect.TypeOf(ptr).Elem())
+}
+
+// PresentType returns true if the input of type t has a provider.
+func (p Presence) PresentType(t reflect.Type) bool {
+	return !p.missing[t]
+}
+
+// optionalInputValues returns the values that are supplied directly
+// to a provider annotated with OptionalInputs rather than coming from
+// the value collection: zero values for inputs that are missing and
+// the Presence.
+func optionalInputValues(fm *provider) map[int]reflect.Value {
+	if !fm.optionalInputs {
+		return nil
+	}
+	presence := Presence{
+		missing: make(map[reflect.Type]bool),
+	}
+	for tc := range fm.d.missingOptional {
+		presence.missing[tc.Type()] = true
+	}
+	fixed := make(map[int]reflect.Value)
+	for i, tc := range fm.flows[inputParams] {
+		switch {
+		case tc == presenceTypeCode:
+			fixed[i] = reflect.ValueOf(presence)
+		case fm.d.missingOptional[tc]:
+			fixed[i] = reflect.Zero(tc.Type())
+		}
+	}
+	return fixed
+}
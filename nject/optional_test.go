@@ -0,0 +1,82 @@
+package nject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionalInputs(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var seen []string
+		middleware := Provide("middleware", OptionalInputs(func(inner func() s4, s s1, p Presence) s4 {
+			if p.Present((*s1)(nil)) {
+				seen = append(seen, "with "+string(s))
+			} else {
+				seen = append(seen, "without "+string(s))
+			}
+			return inner()
+		}))
+		final := func() s4 { return "done" }
+
+		var withS1 func(s1) s4
+		require.NoError(t, Sequence("with", middleware, final).Bind(&withS1, nil))
+		var withoutS1 func() s4
+		require.NoError(t, Sequence("without", middleware, final).Bind(&withoutS1, nil))
+
+		assert.Equal(t, s4("done"), withS1("a"))
+		assert.Equal(t, s4("done"), withoutS1())
+		assert.Equal(t, []string{"with a", "without "}, seen)
+
+		// s1 has a provider, but that provider cannot be included
+		var excludedSource func() s4
+		require.NoError(t, Sequence("excluded",
+			func(s s3) s1 { return s1(s) },
+			middleware,
+			final,
+		).Bind(&excludedSource, nil))
+		assert.Equal(t, s4("done"), excludedSource())
+		assert.Equal(t, []string{"with a", "without ", "without "}, seen)
+
+		// without the annotation, the middleware is left out
+		var nope func() s4
+		require.NoError(t, Sequence("nope", Provide("plain", func(inner func() s4, s s1) s4 {
+			seen = append(seen, "plain")
+			return inner()
+		}), final).Bind(&nope, nil))
+		assert.Equal(t, s4("done"), nope())
+		assert.Equal(t, []string{"with a", "without ", "without "}, seen)
+	})
+}
+
+func TestOptionalInputsStatic(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s2
+		require.NoError(t, Sequence("static",
+			Cacheable(OptionalInputs(func(s s1, p Presence) s2 {
+				if p.Present((*s1)(nil)) {
+					return "present"
+				}
+				return s2("missing" + s)
+			})),
+			func(s s2) s2 { return s },
+		).Bind(&invoke, nil))
+		assert.Equal(t, s2("missing"), invoke())
+
+		var presenceOnlyWithAnnotation func() s2
+		require.NoError(t, Sequence("presence",
+			func() s2 { return "s2" },
+			OptionalInputs(func(s s2, p Presence) s2 { return s }),
+		).Bind(&presenceOnlyWithAnnotation, nil))
+		assert.Equal(t, s2("s2"), presenceOnlyWithAnnotation())
+
+		err := Sequence("presence",
+			func() s2 { return "s2" },
+			func(s s2, p Presence) s2 { return s },
+		).Bind(&presenceOnlyWithAnnotation, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "no match for its input parameter nject.Presence")
+		}
+	})
+}
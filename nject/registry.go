@@ -0,0 +1,439 @@
+package nject
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry maps names to providers so that chains can be assembled
+// from a description with Load instead of in code.  That lets the order
+// of providers, or which implementation is used, differ between
+// deployments without recompiling.  Descriptions must be JSON.  YAML is
+// not supported so convert YAML descriptions to JSON before loading them.
+//
+// Most programs use the package-level Register and Load functions.
+// Use NewRegistry for a separate set of names.
+type Registry struct {
+	lock      sync.RWMutex
+	providers map[string]interface{}
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]interface{}),
+	}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Register adds a provider to the Registry.  The provider can be anything
+// that can be given to Sequence, including a *Collection.  Names are
+// usually dotted, eg "db.postgres".  Register panics if the name is
+// already registered.
+func (r *Registry) Register(name string, provider interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, found := r.providers[name]; found {
+		panic(fmt.Sprintf("nject: provider %q is already registered", name))
+	}
+	r.providers[name] = provider
+}
+
+// Register adds a provider to the default Registry
+func Register(name string, provider interface{}) {
+	defaultRegistry.Register(name, provider)
+}
+
+func (r *Registry) lookup(name string) (interface{}, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	p, found := r.providers[name]
+	return p, found
+}
+
+// Load builds a Collection from a JSON description of the providers in it.
+// Only JSON is accepted.  The description lists registered providers in order.  Each one is either
+// the name it was registered with or an object with the name and the
+// annotations for it:
+//
+//	{
+//		"providers": [
+//			"config.env",
+//			{"name": "db.postgres", "cacheable": true},
+//			{"name": "middleware.auth", "required": true},
+//			{"name": "middleware.metrics", "desired": true},
+//			"handler.users"
+//		]
+//	}
+//
+// The annotations are "cacheable", "required", and "desired".  A provider
+// cannot be both required and desired and each annotation can only be
+// given once.  Wrappers cannot be cacheable and neither can the last
+// provider in the description: it is the final function when the
+// Collection is bound by itself.  Providers are named with their
+// registered names unless they are collections.
+//
+// Problems with the description, including names that are not registered,
+// are returned as a *LoadError that lists all of them with line numbers.
+func (r *Registry) Load(name string, description io.Reader) (*Collection, error) {
+	data, err := ioutil.ReadAll(description)
+	if err != nil {
+		return nil, err
+	}
+	l := &loader{
+		registry: r,
+		data:     data,
+		dec:      json.NewDecoder(bytes.NewReader(data)),
+		err:      &LoadError{Name: name},
+	}
+	providers := l.load()
+	if len(l.err.Problems) > 0 {
+		sort.SliceStable(l.err.Problems, func(i, j int) bool {
+			return l.err.Problems[i].Line < l.err.Problems[j].Line
+		})
+		return nil, l.err
+	}
+	return Sequence(name, providers...), nil
+}
+
+// Load builds a Collection from a JSON description using the default
+// Registry
+func Load(name string, description io.Reader) (*Collection, error) {
+	return defaultRegistry.Load(name, description)
+}
+
+// LoadError is returned by Load when a description has problems
+type LoadError struct {
+	// Name is the name given to Load
+	Name     string
+	Problems []LoadProblem
+}
+
+// LoadProblem is one problem with a description
+type LoadProblem struct {
+	// Line starts at 1
+	Line    int
+	Message string
+}
+
+func (e *LoadError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("cannot load %s: %s", e.Name, strings.Join(problems, "; "))
+}
+
+// loadEntry is the object form of a provider in a description
+type loadEntry struct {
+	Name      string `json:"name"`
+	Cacheable bool   `json:"cacheable"`
+	Required  bool   `json:"required"`
+	Desired   bool   `json:"desired"`
+}
+
+// loadEntryFields are the fields of loadEntry and the kind of value
+// that each one takes
+var loadEntryFields = map[string]string{
+	"name":      "string",
+	"cacheable": "boolean",
+	"required":  "boolean",
+	"desired":   "boolean",
+}
+
+type loader struct {
+	registry *Registry
+	data     []byte
+	dec      *json.Decoder
+	err      *LoadError
+}
+
+func (l *loader) problem(offset int64, format string, args ...interface{}) {
+	l.err.Problems = append(l.err.Problems, LoadProblem{
+		Line:    l.line(offset),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// line converts an offset into the description into a line number
+func (l *loader) line(offset int64) int {
+	if offset > int64(len(l.data)) {
+		offset = int64(len(l.data))
+	}
+	return 1 + bytes.Count(l.data[:offset], []byte("\n"))
+}
+
+// next returns the offset of the start of the next value.  The
+// decoder's offset is the end of the previous token.
+func (l *loader) next() int64 {
+	return skipSpace(l.data, l.dec.InputOffset())
+}
+
+// skipSpace returns the offset of the first byte at or after offset
+// that is not whitespace or a separator
+func skipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+			continue
+		}
+		break
+	}
+	return offset
+}
+
+func (l *loader) syntaxError(err error) {
+	if se, ok := err.(*json.SyntaxError); ok {
+		l.problem(se.Offset, "%s", se)
+		return
+	}
+	l.problem(l.dec.InputOffset(), "%s", err)
+}
+
+func (l *loader) delim(want json.Delim) bool {
+	offset := l.next()
+	tok, err := l.dec.Token()
+	if err != nil {
+		l.syntaxError(err)
+		return false
+	}
+	if tok != want {
+		l.problem(offset, "expected %s but found %v", want, tok)
+		return false
+	}
+	return true
+}
+
+func (l *loader) load() []interface{} {
+	if !l.delim('{') {
+		return nil
+	}
+	var providers []interface{}
+	var found bool
+	for l.dec.More() {
+		offset := l.next()
+		tok, err := l.dec.Token()
+		if err != nil {
+			l.syntaxError(err)
+			return nil
+		}
+		switch tok {
+		case "providers":
+			found = true
+			var ok bool
+			providers, ok = l.loadProviders()
+			if !ok {
+				return nil
+			}
+		default:
+			l.problem(offset, "unknown field %v", tok)
+			var skip json.RawMessage
+			if err := l.dec.Decode(&skip); err != nil {
+				l.syntaxError(err)
+				return nil
+			}
+		}
+	}
+	if !l.delim('}') {
+		return nil
+	}
+	if !found {
+		l.problem(0, "missing \"providers\"")
+	}
+	return providers
+}
+
+// loadProviders returns false if the description cannot be parsed
+func (l *loader) loadProviders() ([]interface{}, bool) {
+	if !l.delim('[') {
+		return nil, false
+	}
+	var providers []interface{}
+	for l.dec.More() {
+		offset := l.next()
+		var raw json.RawMessage
+		if err := l.dec.Decode(&raw); err != nil {
+			l.syntaxError(err)
+			return nil, false
+		}
+		if p := l.loadProvider(offset, raw, !l.dec.More()); p != nil {
+			providers = append(providers, p)
+		}
+	}
+	return providers, l.delim(']')
+}
+
+// loadField is one field of the object form of a provider
+type loadField struct {
+	name   string
+	offset int64
+	value  json.RawMessage
+}
+
+// loadFields splits an object into its fields, keeping duplicates.  It
+// returns false if raw is not an object.
+func loadFields(offset int64, raw json.RawMessage) ([]loadField, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var fields []loadField
+	for dec.More() {
+		fieldOffset := offset + skipSpace(raw, dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		fields = append(fields, loadField{
+			name:   tok.(string),
+			offset: fieldOffset,
+			value:  value,
+		})
+	}
+	return fields, true
+}
+
+// loadEntryFrom fills in entry from the object form of a provider.  Every
+// problem with the fields is reported.  The offsets of the annotations
+// that are set are returned so that later problems can be reported on
+// the line of the annotation.  valid is false if there were problems.
+// isObject is false if raw is not an object at all.
+func (l *loader) loadEntryFrom(offset int64, raw json.RawMessage, entry *loadEntry) (offsets map[string]int64, valid bool, isObject bool) {
+	fields, ok := loadFields(offset, raw)
+	if !ok {
+		l.problem(offset, "a provider must be a name or an object, not %s", raw)
+		return nil, false, false
+	}
+	valid = true
+	var unknown []string
+	offsets = make(map[string]int64, len(fields))
+	for _, field := range fields {
+		if _, known := loadEntryFields[field.name]; !known {
+			unknown = append(unknown, field.name)
+			continue
+		}
+		if _, duplicate := offsets[field.name]; duplicate {
+			l.problem(field.offset, "annotation %s is given more than once", field.name)
+			valid = false
+			continue
+		}
+		offsets[field.name] = field.offset
+		var err error
+		switch field.name {
+		case "name":
+			err = json.Unmarshal(field.value, &entry.Name)
+		case "cacheable":
+			err = json.Unmarshal(field.value, &entry.Cacheable)
+		case "required":
+			err = json.Unmarshal(field.value, &entry.Required)
+		case "desired":
+			err = json.Unmarshal(field.value, &entry.Desired)
+		}
+		if err != nil {
+			l.problem(field.offset, "%s must be a %s, not %s", field.name, loadEntryFields[field.name], field.value)
+			valid = false
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		l.problem(offset, "unknown annotations %s", strings.Join(unknown, ", "))
+		valid = false
+	}
+	return offsets, valid, true
+}
+
+// loadProvider returns nil if there are problems with the entry.  Every
+// problem with the entry is reported.  last is true for the final provider
+// in the description.
+func (l *loader) loadProvider(offset int64, raw json.RawMessage, last bool) interface{} {
+	var entry loadEntry
+	var offsets map[string]int64
+	valid := true
+	if err := json.Unmarshal(raw, &entry.Name); err != nil {
+		var isObject bool
+		offsets, valid, isObject = l.loadEntryFrom(offset, raw, &entry)
+		if !isObject {
+			return nil
+		}
+	}
+	at := func(annotation string) int64 {
+		if o, found := offsets[annotation]; found {
+			return o
+		}
+		return offset
+	}
+	if entry.Name == "" {
+		if _, given := offsets["name"]; !given || valid {
+			l.problem(offset, "a provider must have a name")
+		}
+		return nil
+	}
+	p, found := l.registry.lookup(entry.Name)
+	if !found {
+		l.problem(at("name"), "no provider is registered as %q", entry.Name)
+		valid = false
+	}
+	if entry.Required && entry.Desired {
+		l.problem(at("desired"), "%s cannot be both required and desired", entry.Name)
+		valid = false
+	}
+	if entry.Cacheable && found {
+		if t, isFunc := registeredFunc(p); isFunc {
+			switch {
+			case t.NumIn() > 0 && t.In(0).Kind() == reflect.Func && t.In(0).Name() == "":
+				l.problem(at("cacheable"), "%s cannot be cacheable because it is a wrapper", entry.Name)
+				valid = false
+			case last:
+				l.problem(at("cacheable"), "%s cannot be cacheable because it is the final function", entry.Name)
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return nil
+	}
+	if _, isCollection := p.(*Collection); !isCollection {
+		p = Provide(entry.Name, p)
+	}
+	if entry.Cacheable {
+		p = Cacheable(p)
+	}
+	if entry.Required {
+		p = Required(p)
+	}
+	if entry.Desired {
+		p = Desired(p)
+	}
+	return p
+}
+
+// registeredFunc returns the type of a registered provider if it is a
+// function.  Collections are not checked.
+func registeredFunc(p interface{}) (reflect.Type, bool) {
+	switch v := p.(type) {
+	case *provider:
+		p = v.fn
+	case provider:
+		p = v.fn
+	case *Collection, Collection:
+		return nil, false
+	}
+	t := reflect.TypeOf(p)
+	if t == nil || t.Kind() != reflect.Func {
+		return nil, false
+	}
+	return t, true
+}
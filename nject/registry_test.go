@@ -0,0 +1,168 @@
+package nject
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegistry(called *[]string) *Registry {
+	r := NewRegistry()
+	r.Register("config", func() s1 {
+		*called = append(*called, "config")
+		return "config"
+	})
+	r.Register("db.postgres", func(c s1) s2 {
+		*called = append(*called, "postgres")
+		return "postgres"
+	})
+	r.Register("db.memory", func() s2 {
+		*called = append(*called, "memory")
+		return "memory"
+	})
+	r.Register("audit", func() {
+		*called = append(*called, "audit")
+	})
+	r.Register("handler", Sequence("handler", func(db s2) string {
+		*called = append(*called, "handler")
+		return string(db)
+	}))
+	return r
+}
+
+func TestRegistryLoad(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called []string
+		r := testRegistry(&called)
+		c, err := r.Load("service", strings.NewReader(`{
+			"providers": [
+				{"name": "config", "cacheable": true},
+				{"name": "db.postgres", "cacheable": true},
+				{"name": "audit", "required": true},
+				"handler"
+			]
+		}`))
+		require.NoError(t, err)
+		var invoke func() string
+		require.NoError(t, c.Bind(&invoke, nil))
+		assert.Equal(t, "postgres", invoke())
+		assert.Equal(t, "postgres", invoke())
+		assert.Equal(t, []string{"config", "postgres", "audit", "handler", "audit", "handler"}, called)
+
+		var d *Debugging
+		require.NoError(t, Sequence("debug", func(dbg *Debugging) { d = dbg }, c).Bind(&invoke, nil))
+		invoke()
+		assert.Contains(t, d.NamesIncluded, "db.postgres")
+	})
+}
+
+func TestRegistrySwap(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called []string
+		r := testRegistry(&called)
+		c, err := r.Load("service", strings.NewReader(`{"providers": ["db.memory", "handler"]}`))
+		require.NoError(t, err)
+		var invoke func() string
+		require.NoError(t, c.Bind(&invoke, nil))
+		assert.Equal(t, "memory", invoke())
+	})
+}
+
+func TestRegistryLoadProblems(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called []string
+		r := testRegistry(&called)
+		_, err := r.Load("broken", strings.NewReader(`{
+			"providers": [
+				"config",
+				"db.mysql",
+				{"name": "audit", "required": true, "desired": true},
+				{"name": "handler", "memoize": true},
+				17
+			]
+		}`))
+		require.Error(t, err)
+		var le *LoadError
+		require.True(t, errors.As(err, &le))
+		assert.Equal(t, []LoadProblem{
+			{Line: 4, Message: `no provider is registered as "db.mysql"`},
+			{Line: 5, Message: "audit cannot be both required and desired"},
+			{Line: 6, Message: "unknown annotations memoize"},
+			{Line: 7, Message: "a provider must be a name or an object, not 17"},
+		}, le.Problems)
+	})
+}
+
+func TestRegistryLoadAnnotations(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var called []string
+		r := testRegistry(&called)
+		r.Register("timing", func(inner func()) { inner() })
+		_, err := r.Load("annotations", strings.NewReader(`{
+			"providers": [
+				{"name": "timing", "cacheable": true},
+				{"name": "config", "cacheable": true, "cacheable": false},
+				{
+					"name": "db.mysql",
+					"required": true,
+					"desired": true,
+					"cacheable": "yes"
+				},
+				{"name": "audit", "cacheable": true}
+			]
+		}`))
+		require.Error(t, err)
+		var le *LoadError
+		require.True(t, errors.As(err, &le))
+		assert.Equal(t, []LoadProblem{
+			{Line: 3, Message: "timing cannot be cacheable because it is a wrapper"},
+			{Line: 4, Message: "annotation cacheable is given more than once"},
+			{Line: 6, Message: `no provider is registered as "db.mysql"`},
+			{Line: 8, Message: "db.mysql cannot be both required and desired"},
+			{Line: 9, Message: `cacheable must be a boolean, not "yes"`},
+			{Line: 11, Message: "audit cannot be cacheable because it is the final function"},
+		}, le.Problems)
+	})
+}
+
+func TestRegistryLoadSyntax(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		r := NewRegistry()
+		r.Register("config", s1("config"))
+		_, err := r.Load("syntax", strings.NewReader("{\n\"providers\": [\n\"config\"\n\"x\"]}"))
+		var le *LoadError
+		require.True(t, errors.As(err, &le), "error %v", err)
+		require.Len(t, le.Problems, 1)
+		assert.Equal(t, 4, le.Problems[0].Line)
+
+		_, err = r.Load("missing", strings.NewReader(`{"provider": []}`))
+		assert.EqualError(t, err, `cannot load missing: line 1: unknown field provider; line 1: missing "providers"`)
+	})
+}
+
+func TestRegistryDuplicate(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		r := NewRegistry()
+		r.Register("x", s1("x"))
+		assert.Panics(t, func() { r.Register("x", s1("y")) })
+	})
+}
+
+var registerDefaultOnce sync.Once
+
+func TestRegisterDefault(t *testing.T) {
+	registerDefaultOnce.Do(func() {
+		Register("registry_test.value", s3("default"))
+	})
+	wrapTest(t, func(t *testing.T) {
+		c, err := Load("default", strings.NewReader(`{"providers": ["registry_test.value"]}`))
+		require.NoError(t, err)
+		var got s3
+		require.NoError(t, Run("run", c, func(v s3) { got = v }))
+		assert.Equal(t, s3("default"), got)
+	})
+}
@@ -0,0 +1,202 @@
+package nject
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Generation identifies one run of the STATIC chain.  Bindings made with
+// the Reloader option get a new Generation each time they are reloaded.
+// Providers can consume *Generation to find out which generation they
+// are part of and to register teardown hooks for the values they create:
+//
+//	func(g *nject.Generation, cfg Config) *sql.DB {
+//		db := open(cfg)
+//		g.OnRetire(func() { db.Close() })
+//		return db
+//	}
+type Generation struct {
+	// Number starts at 1 and goes up with each reload.  A reload that
+	// fails uses up a number so numbers may be skipped.
+	Number int
+
+	lock    sync.Mutex
+	hooks   []func()
+	retired bool
+}
+
+var generationTypeCode = getTypeCode(reflect.TypeOf(&Generation{}))
+
+// OnRetire registers a function to be called when the generation is
+// retired: after it has been replaced by a reload and all of the
+// invocations that were using it have finished.  Hooks are called in the
+// reverse of the order they were registered.  If the generation is already
+// retired, fn is called right away.
+//
+// Only bindings made with the Reloader option retire generations.
+// Without Reloader, the single generation lasts as long as the binding
+// and hooks registered with OnRetire are never called.
+//
+// With RetryableInit, the hooks that were registered while the STATIC
+// chain ran are called when that run fails: the next run starts over.
+func (g *Generation) OnRetire(fn func()) {
+	g.lock.Lock()
+	if !g.retired {
+		g.hooks = append(g.hooks, fn)
+		g.lock.Unlock()
+		return
+	}
+	g.lock.Unlock()
+	fn()
+}
+
+func (g *Generation) retire() {
+	g.lock.Lock()
+	g.retired = true
+	g.lock.Unlock()
+	g.runHooks()
+}
+
+// runHooks calls the hooks that have been registered so far
+func (g *Generation) runHooks() {
+	g.lock.Lock()
+	hooks := g.hooks
+	g.hooks = nil
+	g.lock.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// Reloader is a BindOption that makes the binding reloadable.  Bind sets
+// *reload to a function that runs the STATIC chain again with the same
+// inputs that were given to the init function.  If that succeeds, the
+// new values are used by invocations that start after reload returns.
+// Invocations that are already running finish with the old values and
+// then the old Generation is retired.  If the STATIC chain fails, the old
+// values are kept and the error is returned.
+//
+// Reloading before the binding has been initialized returns an error.
+// Providers annotated with Memoize are not re-run when their inputs have
+// not changed.
+func Reloader(reload *func() error) BindOption {
+	return func(o *bindOptions) {
+		o.reload = reload
+	}
+}
+
+// generation is a Generation along with the values that the STATIC chain
+// created for it
+type generation struct {
+	*Generation
+	values   valueCollection
+	refs     int
+	replaced bool
+}
+
+// generations tracks which generation new invocations should use
+// and how many invocations are using each generation.
+type generations struct {
+	lock     sync.Mutex
+	current  *generation
+	building *Generation // given to providers while the STATIC chain runs
+	numbered int         // the Number of the last Generation created
+	reload   sync.Mutex  // only one reload at a time
+}
+
+func newGenerations(baseValues valueCollection) *generations {
+	first := &generation{
+		Generation: &Generation{Number: 1},
+		values:     baseValues,
+	}
+	return &generations{
+		current:  first,
+		building: first.Generation,
+		numbered: first.Number,
+	}
+}
+
+// generation returns the Generation that the STATIC chain is building
+func (gs *generations) generation() *Generation {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	return gs.building
+}
+
+// initialized replaces the values of the first generation when the STATIC
+// chain has run.  Invocations that already have the first generation keep
+// the values they copied.
+func (gs *generations) initialized(values valueCollection) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.current.values = values
+}
+
+// abandoned calls the hooks that were registered by a run of the STATIC
+// chain that failed and will be retried.  The first generation is kept
+// for the next run.
+func (gs *generations) abandoned() {
+	gs.lock.Lock()
+	g := gs.current.Generation
+	gs.lock.Unlock()
+	g.runHooks()
+}
+
+// acquire returns the current generation and its values.  The values
+// must not be modified.
+func (gs *generations) acquire() (*generation, valueCollection) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.current.refs++
+	return gs.current, gs.current.values
+}
+
+func (gs *generations) release(g *generation) {
+	gs.lock.Lock()
+	g.refs--
+	retire := g.replaced && g.refs == 0
+	gs.lock.Unlock()
+	if retire {
+		g.retire()
+	}
+}
+
+func (gs *generations) swap(g *generation) {
+	gs.lock.Lock()
+	old := gs.current
+	gs.current = g
+	old.replaced = true
+	retire := old.refs == 0
+	gs.lock.Unlock()
+	if retire {
+		old.retire()
+	}
+}
+
+// makeReload creates the function that Reloader provides
+func (gs *generations) makeReload(state *initState, runStaticChain func(valueCollection) error) func() error {
+	return func() error {
+		gs.reload.Lock()
+		defer gs.reload.Unlock()
+		inputs, initialized := state.succeeded()
+		if !initialized {
+			return errors.New("cannot reload before initialization has succeeded")
+		}
+		gs.lock.Lock()
+		// Numbers are not reused, even when a reload fails
+		gs.numbered++
+		next := &generation{
+			Generation: &Generation{Number: gs.numbered},
+			values:     inputs.Copy(),
+		}
+		gs.building = next.Generation
+		gs.lock.Unlock()
+		if err := runStaticChain(next.values); err != nil {
+			next.retire()
+			return err
+		}
+		gs.swap(next)
+		return nil
+	}
+}
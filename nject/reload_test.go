@@ -0,0 +1,157 @@
+package nject
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var lock sync.Mutex
+		var retired []int
+		var loads int
+		var fail bool
+		hold := make(chan struct{})
+		started := make(chan struct{})
+
+		var reload func() error
+		var initFunc func(s1)
+		var invoke func(s3) (string, error)
+		require.NoError(t, Sequence("reload",
+			MustCache(func(g *Generation, s s1) (s2, TerminalError) {
+				loads++
+				if fail {
+					g.OnRetire(func() {
+						lock.Lock()
+						defer lock.Unlock()
+						retired = append(retired, -g.Number)
+					})
+					return "", errors.New("reload failed")
+				}
+				g.OnRetire(func() {
+					lock.Lock()
+					defer lock.Unlock()
+					retired = append(retired, g.Number)
+				})
+				return s2(fmt.Sprintf("%s-%d", s, loads)), nil
+			}),
+			func(s s2, g *Generation, mode s3) (string, error) {
+				if mode == "hold" {
+					close(started)
+					<-hold
+				}
+				return fmt.Sprintf("%s gen%d", s, g.Number), nil
+			},
+		).Bind(&invoke, &initFunc, Reloader(&reload)))
+
+		assert.Error(t, reload(), "not initialized yet")
+
+		initFunc("cfg")
+		got, err := invoke("")
+		require.NoError(t, err)
+		assert.Equal(t, "cfg-1 gen1", got)
+
+		require.NoError(t, reload())
+		got, err = invoke("")
+		require.NoError(t, err)
+		assert.Equal(t, "cfg-2 gen2", got)
+		lock.Lock()
+		assert.Equal(t, []int{1}, retired)
+		lock.Unlock()
+
+		// an invocation that is running keeps using its generation
+		held := make(chan string)
+		go func() {
+			s, _ := invoke("hold")
+			held <- s
+		}()
+		<-started
+		require.NoError(t, reload())
+		got, err = invoke("")
+		require.NoError(t, err)
+		assert.Equal(t, "cfg-3 gen3", got)
+		lock.Lock()
+		assert.Equal(t, []int{1}, retired, "generation 2 is still in use")
+		lock.Unlock()
+		close(hold)
+		assert.Equal(t, "cfg-2 gen2", <-held)
+		lock.Lock()
+		assert.Equal(t, []int{1, 2}, retired)
+		lock.Unlock()
+
+		// a failed reload keeps the old generation
+		fail = true
+		assert.EqualError(t, reload(), "reload failed")
+		got, err = invoke("")
+		require.NoError(t, err)
+		assert.Equal(t, "cfg-3 gen3", got)
+		lock.Lock()
+		assert.Equal(t, []int{1, 2, -4}, retired)
+		lock.Unlock()
+
+		// the number used by the failed reload is not reused
+		fail = false
+		require.NoError(t, reload())
+		got, err = invoke("")
+		require.NoError(t, err)
+		assert.Equal(t, "cfg-5 gen5", got)
+		lock.Lock()
+		assert.Equal(t, []int{1, 2, -4, 3}, retired)
+		lock.Unlock()
+	})
+}
+
+func TestReloadRetryableInit(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var retired []string
+		var fail bool
+		var reload func() error
+		var initFunc func(s1)
+		var invoke func() (s2, error)
+		require.NoError(t, Sequence("retry",
+			MustCache(func(g *Generation, s s1) (s2, TerminalError) {
+				attempt := fmt.Sprintf("%s gen%d", s, g.Number)
+				g.OnRetire(func() { retired = append(retired, attempt) })
+				if fail {
+					return "", errors.New("init failed")
+				}
+				return s2(attempt), nil
+			}),
+			func(s s2) (s2, error) { return s, nil },
+		).Bind(&invoke, &initFunc, RetryableInit(), Reloader(&reload)))
+
+		fail = true
+		initFunc("first")
+		_, err := invoke()
+		assert.EqualError(t, err, "init failed")
+		assert.Equal(t, []string{"first gen1"}, retired, "the failed attempt is torn down")
+
+		fail = false
+		initFunc("second")
+		got, err := invoke()
+		require.NoError(t, err)
+		assert.Equal(t, s2("second gen1"), got)
+		assert.Equal(t, []string{"first gen1"}, retired)
+
+		require.NoError(t, reload())
+		got, err = invoke()
+		require.NoError(t, err)
+		assert.Equal(t, s2("second gen2"), got)
+		assert.Equal(t, []string{"first gen1", "second gen1"}, retired, "the failed attempt is not torn down again")
+	})
+}
+
+func TestGenerationWithoutReloader(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() int
+		require.NoError(t, Sequence("generation",
+			func(g *Generation) int { return g.Number },
+		).Bind(&invoke, nil))
+		assert.Equal(t, 1, invoke())
+	})
+}
@@ -0,0 +1,149 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy controls how Retry re-runs a provider
+type RetryPolicy struct {
+	// Attempts is the maximum number of times to run the provider.
+	// Values less than one are treated as one.
+	Attempts int
+	// Backoff, if set, returns how long to wait after the given
+	// number of failed attempts (starting at 1) before trying again.
+	Backoff func(failures int) time.Duration
+	// Retryable, if set, decides which errors are worth retrying.
+	// Without it, all errors are retried.
+	Retryable func(err error) bool
+	// Sleep, if set, is used instead of time.Sleep to wait between
+	// attempts.  Set it to test retries without waiting.
+	Sleep func(time.Duration)
+}
+
+// RetryAttempts is provided by providers annotated with Retry.  It is
+// the number of attempts, starting at 1, that have been made.
+type RetryAttempts int
+
+var retryAttemptsType = reflect.TypeOf(RetryAttempts(0))
+
+// Retry creates a provider that is re-run when it fails.  fn must be
+// either a fallible injector or a wrapper.
+//
+// A fallible injector is re-run when it returns a non-nil TerminalError.
+// Its outputs include RetryAttempts.
+//
+// For a wrapper, the rest of the chain below the wrapper is re-run
+// when it returns a non-nil error.  The inner function of the wrapper
+// must return error.  The values passed down the chain are reset to
+// what they were before the first attempt so each attempt starts fresh.
+// RetryAttempts is provided to the rest of the chain.
+//
+// Retry panics if fn is neither a fallible injector nor a wrapper whose
+// inner function returns error.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func Retry(policy RetryPolicy, fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		fm.fn = makeRetry(policy, fm.fn)
+	})
+}
+
+func (p RetryPolicy) again(failures int, err error) bool {
+	if failures >= p.Attempts {
+		return false
+	}
+	if p.Retryable != nil && !p.Retryable(err) {
+		return false
+	}
+	if p.Backoff != nil {
+		if d := p.Backoff(failures); d > 0 {
+			if p.Sleep != nil {
+				p.Sleep(d)
+			} else {
+				time.Sleep(d)
+			}
+		}
+	}
+	return true
+}
+
+func makeRetry(policy RetryPolicy, fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("Retry requires a function, not %T", fn))
+	}
+	t := v.Type()
+	in := typesIn(t)
+	if len(in) > 0 && in[0].Kind() == reflect.Func && in[0].Name() == "" {
+		return makeRetryWrapper(policy, v)
+	}
+	out := typesOut(t)
+	for i, typ := range out {
+		if typ == terminalErrorType {
+			return makeRetryInjector(policy, v, i)
+		}
+	}
+	panic(fmt.Sprintf("Retry requires a fallible injector or a wrapper, not %s", t))
+}
+
+func makeRetryInjector(policy RetryPolicy, v reflect.Value, errorIndex int) interface{} {
+	t := v.Type()
+	out := typesOut(t)
+	// RetryAttempts goes just before the TerminalError
+	retryOut := append(append(append(make([]reflect.Type, 0, len(out)+1), out[:errorIndex]...), retryAttemptsType), out[errorIndex:]...)
+	return reflect.MakeFunc(reflect.FuncOf(typesIn(t), retryOut, t.IsVariadic()), func(inputs []reflect.Value) []reflect.Value {
+		for attempt := 1; ; attempt++ {
+			var results []reflect.Value
+			if t.IsVariadic() {
+				results = v.CallSlice(inputs)
+			} else {
+				results = v.Call(inputs)
+			}
+			err, _ := results[errorIndex].Interface().(error)
+			if err == nil || !policy.again(attempt, err) {
+				return append(append(append(make([]reflect.Value, 0, len(results)+1), results[:errorIndex]...), reflect.ValueOf(RetryAttempts(attempt))), results[errorIndex:]...)
+			}
+		}
+	}).Interface()
+}
+
+func makeRetryWrapper(policy RetryPolicy, v reflect.Value) interface{} {
+	t := v.Type()
+	innerType := t.In(0)
+	errorIndex := -1
+	for i, typ := range typesOut(innerType) {
+		if typ == errorType {
+			errorIndex = i
+			break
+		}
+	}
+	if errorIndex == -1 {
+		panic(fmt.Sprintf("Retry requires the inner function of wrapper %s to return error", t))
+	}
+	if innerType.IsVariadic() {
+		panic(fmt.Sprintf("Retry cannot be used with wrappers that have variadic inner functions like %s", t))
+	}
+	retryInnerType := reflect.FuncOf(append(typesIn(innerType), retryAttemptsType), typesOut(innerType), false)
+	in := typesIn(t)
+	in[0] = retryInnerType
+	return reflect.MakeFunc(reflect.FuncOf(in, typesOut(t), t.IsVariadic()), func(inputs []reflect.Value) []reflect.Value {
+		retryInner := inputs[0]
+		inner := reflect.MakeFunc(innerType, func(args []reflect.Value) []reflect.Value {
+			for attempt := 1; ; attempt++ {
+				results := retryInner.Call(append(append(make([]reflect.Value, 0, len(args)+1), args...), reflect.ValueOf(RetryAttempts(attempt))))
+				err, _ := results[errorIndex].Interface().(error)
+				if err == nil || !policy.again(attempt, err) {
+					return results
+				}
+			}
+		})
+		wrapperInputs := append(make([]reflect.Value, 0, len(inputs)), inner)
+		wrapperInputs = append(wrapperInputs, inputs[1:]...)
+		if t.IsVariadic() {
+			return v.CallSlice(wrapperInputs)
+		}
+		return v.Call(wrapperInputs)
+	}).Interface()
+}
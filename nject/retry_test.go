@@ -0,0 +1,88 @@
+package nject
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("transient")
+
+func TestRetryInjector(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var slept []time.Duration
+		policy := RetryPolicy{
+			Attempts:  3,
+			Backoff:   func(failures int) time.Duration { return time.Duration(failures) * time.Second },
+			Retryable: func(err error) bool { return err == errTransient },
+			Sleep:     func(d time.Duration) { slept = append(slept, d) },
+		}
+		var failures int
+		var failWith error
+		var invoke func() (string, error)
+		require.NoError(t, Sequence("retry",
+			Retry(policy, func() (s1, TerminalError) {
+				if failures > 0 {
+					failures--
+					return "", failWith
+				}
+				return "ok", nil
+			}),
+			func(s s1, n RetryAttempts) string { return string(s) + " " + string(rune('0'+n)) },
+		).Bind(&invoke, nil))
+
+		failures, failWith = 2, errTransient
+		got, err := invoke()
+		require.NoError(t, err)
+		assert.Equal(t, "ok 3", got)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, slept)
+
+		slept = nil
+		failures = 3
+		_, err = invoke()
+		assert.Equal(t, errTransient, err, "out of attempts")
+		assert.Len(t, slept, 2)
+
+		slept = nil
+		failures, failWith = 1, errors.New("permanent")
+		_, err = invoke()
+		assert.EqualError(t, err, "permanent")
+		assert.Empty(t, slept)
+	})
+}
+
+func TestRetryWrapper(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var seen []s2
+		var invoke func(s1) (string, error)
+		require.NoError(t, Sequence("retry",
+			Retry(RetryPolicy{Attempts: 5}, func(inner func(s2) (string, error), s s1) (string, error) {
+				return inner(s2(s))
+			}),
+			func(s s2, n RetryAttempts) s2 {
+				seen = append(seen, s)
+				return s + s2(rune('0'+n))
+			},
+			func(s s2, n RetryAttempts) (s3, TerminalError) {
+				if n < 3 {
+					return "", errTransient
+				}
+				return s3(s), nil
+			},
+			func(s s3) (string, error) { return string(s), nil },
+		).Bind(&invoke, nil))
+
+		got, err := invoke("x")
+		require.NoError(t, err)
+		assert.Equal(t, "x3", got)
+		assert.Equal(t, []s2{"x", "x", "x"}, seen, "each attempt starts with the original values")
+	})
+}
+
+func TestRetryMisuse(t *testing.T) {
+	assert.Panics(t, func() { Retry(RetryPolicy{}, func() s1 { return "" }) })
+	assert.Panics(t, func() { Retry(RetryPolicy{}, func(inner func() s1) s1 { return inner() }) })
+}
@@ -3,7 +3,16 @@ package nject
 // TODO: test MustConsume on terminal injector
 
 import (
+	"encoding/json"
 	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -337,6 +346,23 @@ func TestVariableImplementsInterfaceExact(t *testing.T) {
 	})
 }
 
+var locationRE = regexp.MustCompile(` at [^ ]+\.go:\d+`)
+
+func withoutLocations(a []string) []string {
+	n := make([]string, len(a))
+	for i, s := range a {
+		n[i] = locationRE.ReplaceAllString(s, "")
+	}
+	return n
+}
+
+func withoutLocation(pi ProviderInfo) ProviderInfo {
+	pi.Reason = locationRE.ReplaceAllString(pi.Reason, "")
+	pi.Path = ""
+	pi.Location = ""
+	return pi
+}
+
 func TestInjectorsIncluded(t *testing.T) {
 	wrapTest(t, func(t *testing.T) {
 		assert.NoError(t, Run("run1",
@@ -346,13 +372,13 @@ func TestInjectorsIncluded(t *testing.T) {
 				assert.Equal(t, []string{
 					"static static-injector: Debugging [func() *nject.Debugging]",
 					"literal literal-value: run1(1) [nject.s0]",
-					"static static-injector: TBF(0) [func(nject.s0) nject.s1]",
-					"static static-injector: TBF(1) [func(nject.s1) nject.s2]",
-					"static static-injector: TBF(3) [func(nject.s2) nject.s5]",
+					"static static-injector: run1/TBF(0) [func(nject.s0) nject.s1]",
+					"static static-injector: run1/TBF(1) [func(nject.s1) nject.s2]",
+					"static static-injector: run1/TBF(3) [func(nject.s2) nject.s5]",
 					"invoke invoke-func: run1 invoke func [*func() error]",
-					"run fallible-injector: Run()error [func() nject.TerminalError]",
+					"run fallible-injector: run1/Run()error [func() nject.TerminalError]",
 					"final final-func: run1(3) [func(nject.s5, *nject.Debugging)]",
-				}, d.Included)
+				}, withoutLocations(d.Included))
 			}))
 	})
 }
@@ -386,15 +412,58 @@ func TestInjectorsIncludeExclude(t *testing.T) {
 				assert.Equal(t, []string{
 					"INCLUDED: static static-injector: Debugging [func() *nject.Debugging] BECAUSE used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)",
 					"EXCLUDED: literal literal-value: run1(0) [nject.s3] BECAUSE not used by any remaining providers",
-					"INCLUDED: literal literal-value: run1(1) [nject.s0] BECAUSE used by static-injector: TBF(0) [func(nject.s0) nject.s1] (used by static-injector: TBF(1) [func(nject.s1) nject.s2] (used by static-injector: TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required))))",
-					"INCLUDED: static static-injector: TBF(0) [func(nject.s0) nject.s1] BECAUSE used by static-injector: TBF(1) [func(nject.s1) nject.s2] (used by static-injector: TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)))",
-					"INCLUDED: static static-injector: TBF(1) [func(nject.s1) nject.s2] BECAUSE used by static-injector: TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required))",
-					"EXCLUDED: static static-injector: TBF(2) [func(nject.s3) nject.s4] BECAUSE not used by any remaining providers",
-					"INCLUDED: static static-injector: TBF(3) [func(nject.s2) nject.s5] BECAUSE used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)",
+					"INCLUDED: literal literal-value: run1(1) [nject.s0] BECAUSE used by static-injector: run1/TBF(0) [func(nject.s0) nject.s1] (used by static-injector: run1/TBF(1) [func(nject.s1) nject.s2] (used by static-injector: run1/TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required))))",
+					"INCLUDED: static static-injector: run1/TBF(0) [func(nject.s0) nject.s1] BECAUSE used by static-injector: run1/TBF(1) [func(nject.s1) nject.s2] (used by static-injector: run1/TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)))",
+					"INCLUDED: static static-injector: run1/TBF(1) [func(nject.s1) nject.s2] BECAUSE used by static-injector: run1/TBF(3) [func(nject.s2) nject.s5] (used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required))",
+					"EXCLUDED: static static-injector: run1/TBF(2) [func(nject.s3) nject.s4] BECAUSE not used by any remaining providers",
+					"INCLUDED: static static-injector: run1/TBF(3) [func(nject.s2) nject.s5] BECAUSE used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)",
 					"INCLUDED: invoke invoke-func: run1 invoke func [*func() error] BECAUSE required",
-					"INCLUDED: run fallible-injector: Run()error [func() nject.TerminalError] BECAUSE auto-desired (injector with no outputs)",
+					"INCLUDED: run fallible-injector: run1/Run()error [func() nject.TerminalError] BECAUSE auto-desired (injector with no outputs)",
 					"INCLUDED: final final-func: run1(3) [func(nject.s5, *nject.Debugging)] BECAUSE required",
-				}, d.IncludeExclude)
+				}, withoutLocations(d.IncludeExclude))
+			}))
+	})
+}
+
+func TestInjectorsProviders(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		assert.NoError(t, Run("run1",
+			s3("s3 value"),
+			s0("s0 value"),
+			testSeq, func(s s5, d *Debugging) {
+				require.Len(t, d.Providers, 10)
+				assert.Equal(t, ProviderInfo{
+					Name:     "run1(0)",
+					Index:    0,
+					Class:    "literal-value",
+					Group:    "literal",
+					Included: false,
+					Reason:   "not used by any remaining providers",
+					Flows:    map[string][]string{"outputs": {"nject.s3"}},
+				}, withoutLocation(d.Providers[1]))
+				assert.Equal(t, ProviderInfo{
+					Name:     "TBF(3)",
+					Index:    3,
+					Class:    "static-injector",
+					Group:    "static",
+					Included: true,
+					Reason:   "used by final-func: run1(3) [func(nject.s5, *nject.Debugging)] (required)",
+					Flows: map[string][]string{
+						"inputs":  {"nject.s2"},
+						"outputs": {"nject.s5"},
+					},
+				}, withoutLocation(d.Providers[6]))
+
+				enc, err := json.Marshal(d)
+				require.NoError(t, err)
+				var decoded struct {
+					NamesIncluded []string       `json:"namesIncluded"`
+					Providers     []ProviderInfo `json:"providers"`
+				}
+				require.NoError(t, json.Unmarshal(enc, &decoded))
+				assert.Equal(t, d.NamesIncluded, decoded.NamesIncluded)
+				assert.Equal(t, d.Providers, decoded.Providers)
+				assert.NotContains(t, string(enc), "Trace")
 			}))
 	})
 }
@@ -423,3 +492,125 @@ func TestInjectorsReproduce(t *testing.T) {
 			}))
 	})
 }
+
+func TestInjectorsReproduceFile(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		assert.NoError(t, Run("run1",
+			s3("s3 value"),
+			s0("s0 value"),
+			testSeq, func(s s5, d *Debugging) {
+				src := d.ReproduceFile("regress")
+				_, err := parser.ParseFile(token.NewFileSet(), "regress_test.go", src, 0)
+				require.NoError(t, err, src)
+				formatted, err := format.Source([]byte(src))
+				require.NoError(t, err)
+				assert.Equal(t, string(formatted), src)
+				assert.Regexp(t, `(?m)^package regress$`, src)
+				assert.Contains(t, src, `"github.com/BlueOwlOpenSource/nject/nject"`)
+				assert.Contains(t, src, `nject.Sequence("regression",`)
+				assert.Contains(t, src, `*nject.Debugging`)
+				assert.Contains(t, src, `{"TBF-2", false},`)
+				assert.Contains(t, src, `{"TBF-3", true},`)
+				assert.NotContains(t, src, "wrapTest")
+				assert.NotContains(t, src, "// included")
+				out, passed := runReproduceFile(t, src)
+				assert.True(t, passed, "%s\n%s", out, src)
+			}))
+	})
+}
+
+// runReproduceFile runs a generated regression test in a temporary
+// module that uses this copy of nject.  It returns the output of go test
+// and whether the test passed.
+func runReproduceFile(t *testing.T, src string) (string, bool) {
+	if testing.Short() {
+		t.Skip("builds a temporary module")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not available")
+	}
+	root, err := filepath.Abs("..")
+	require.NoError(t, err)
+	sum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "regress")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	goMod := "module regress\n\ngo 1.13\n\n" +
+		"require github.com/BlueOwlOpenSource/nject v0.0.0\n\n" +
+		"replace github.com/BlueOwlOpenSource/nject => " + root + "\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "regress_test.go"), []byte(src), 0o644))
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	// The dependencies come from the module cache.  Without it, as in
+	// offline CI with an empty cache, the test cannot be built.
+	list := exec.Command(goBin, "list", "-test", "-deps", ".")
+	list.Dir = dir
+	list.Env = env
+	if out, err := list.CombinedOutput(); err != nil {
+		t.Skipf("the module cache does not have the dependencies: %s\n%s", err, out)
+	}
+	cmd := exec.Command(goBin, "test", "-count=1", ".")
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Fatalf("cannot run go test: %s", err)
+		}
+	}
+	require.NotContains(t, string(out), "[build failed]", "%s\n%s", out, src)
+	require.NotContains(t, string(out), "[setup failed]", "%s\n%s", out, src)
+	return string(out), err == nil
+}
+
+func TestReproduceBindError(t *testing.T) {
+	var invoke func(s3)
+	err := Sequence("broken",
+		func(s9) s2 { return "" },
+		func(s2, s3) {},
+	).Bind(&invoke, nil)
+	require.Error(t, err)
+	src := ReproduceBindError(err, "regress")
+	_, perr := parser.ParseFile(token.NewFileSet(), "regress_test.go", src, 0)
+	require.NoError(t, perr, src)
+	assert.Contains(t, src, `nject.Provide("broken-0", func(_ s`)
+	assert.Contains(t, src, "t.Fatal(nject.DetailedError(err))")
+	assert.Equal(t, "", ReproduceBindError(fmt.Errorf("other"), "regress"))
+	out, passed := runReproduceFile(t, src)
+	assert.False(t, passed, "%s\n%s", out, src)
+	// The providers are renamed and the types are anonymized so
+	// compare the reason with the type names removed
+	anonymous := regexp.MustCompile(`\bnject\.s\d\b|\bregress\.s\d+\b`)
+	reason := regexp.MustCompile(`required but [^(]*`)
+	want := reason.FindString(anonymous.ReplaceAllString(err.Error(), "T"))
+	require.NotEmpty(t, want, err.Error())
+	assert.Contains(t, anonymous.ReplaceAllString(out, "T"), want, "%s\n%s", out, src)
+}
+
+func TestProviderPathsAndLocations(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		auth := Sequence("auth",
+			Provide("loadUser", func(s s1) s2 { return s2(s) }),
+			func(s s2) s3 { return s3(s) },
+		)
+		service := Sequence("service", auth)
+
+		var invoke func(s1) *Debugging
+		require.NoError(t, service.Append("endpoint", func(_ s3, d *Debugging) *Debugging { return d }).Bind(&invoke, nil))
+		d := invoke("x")
+		require.Len(t, d.Providers, 5)
+		assert.Equal(t, "service/auth/loadUser", d.Providers[2].Path)
+		assert.Equal(t, "service/auth(1)", d.Providers[3].Path)
+		assert.Regexp(t, `^nject/run_test\.go:\d+$`, d.Providers[2].Location)
+		assert.Contains(t, d.Included[2], "service/auth/loadUser [func(nject.s1) nject.s2] at nject/run_test.go:")
+
+		var invoke2 func() s3
+		err := Sequence("endpoint", service, Required(func(s s3) s3 { return s })).Bind(&invoke2, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "endpoint/service/auth/loadUser [func(nject.s1) nject.s2] at nject/run_test.go:")
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package nject
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Strict is a BindOption that makes Bind fail if any provider in
+// the Collection is left out of the chain or if a provider is
+// shadowed by another provider.  An output is shadowed by a later
+// provider of the same type and a returned value is shadowed by an
+// earlier provider of the same type.  When an interface is matched by a
+// Loose match, the provider that is picked shadows the other providers
+// that could have been picked.  Each shadowed provider is reported once.
+//
+// Normally, nject silently drops providers that are not needed.  That
+// is what you want in production, but it can hide mistakes: a provider
+// that is never used or a type that is provided twice so that the
+// earlier provider is never consulted.  Strict is meant for use in
+// tests and CI.
+//
+// Providers annotated with Overrides are allowed to shadow earlier
+// providers and the providers they shadow are not reported as unused.
+// Default providers are not reported either.
+func Strict() BindOption {
+	return func(o *bindOptions) {
+		o.strict = true
+	}
+}
+
+// StrictWarnings is like Strict except that the problems are written
+// to w, one per line, and Bind does not fail because of them.
+func StrictWarnings(w io.Writer) BindOption {
+	return func(o *bindOptions) {
+		o.strictWarnings = w
+	}
+}
+
+// strictProblems looks for providers that are not included and
+// for providers that are shadowed by later providers.  It must be called
+// after computeDependenciesAndInclusion.
+func strictProblems(funcs []*provider) []string {
+	s := newShadows()
+
+	// Outputs flow down the chain so a provider is shadowed by a
+	// later provider of the same type.
+	providedBy := make(map[typeCode]*provider)
+	for _, fm := range funcs {
+		if fm.include {
+			s.loose(fm, inputParams, fm.downRmap, providedBy)
+		}
+		consumes := make(map[typeCode]bool)
+		for _, tc := range fm.flows[inputParams] {
+			consumes[tc] = true
+		}
+		for _, tc := range fm.flows[outputParams] {
+			if tc == noTypeCode {
+				continue
+			}
+			earlier, found := providedBy[tc]
+			if !recordShadower(providedBy, fm, tc, earlier, found) {
+				continue
+			}
+			if found && !consumes[tc] {
+				s.add(fm, earlier, tc, "")
+			}
+		}
+	}
+
+	// Returned values flow up the chain so a provider is shadowed by an
+	// earlier provider of the same type.  The error returned by a fallible
+	// injector is only returned when it fails so it does not shadow
+	// anything.
+	returnedBy := make(map[typeCode]*provider)
+	for i := len(funcs) - 1; i >= 0; i-- {
+		fm := funcs[i]
+		if fm.include {
+			s.loose(fm, returnedParams, fm.upRmap, returnedBy)
+		}
+		if fm.class == fallibleInjectorFunc {
+			continue
+		}
+		consumes := make(map[typeCode]bool)
+		for _, tc := range fm.flows[returnedParams] {
+			consumes[tc] = true
+		}
+		for _, tc := range fm.flows[returnParams] {
+			if tc == noTypeCode {
+				continue
+			}
+			later, found := returnedBy[tc]
+			if !recordShadower(returnedBy, fm, tc, later, found) {
+				continue
+			}
+			if found && !consumes[tc] {
+				s.add(fm, later, tc, "returned ")
+			}
+		}
+	}
+
+	problems := s.problems()
+	for _, fm := range funcs {
+		if _, shadowed := s.by[fm]; shadowed {
+			// already reported
+			continue
+		}
+		if fm.include || fm.isSynthetic || fm.class == invokeFunc || fm.class == initFunc || fm.isDefault || len(fm.defaultTypes) > 0 || s.overridden[fm] {
+			continue
+		}
+		reason := "not used"
+		if fm.cannotInclude != nil {
+			reason = fm.cannotInclude.Error()
+		}
+		problems = append(problems, fmt.Sprintf("%s is not included: %s", fm, reason))
+	}
+	return problems
+}
+
+// recordShadower records fm as the provider of tc in providedBy and
+// returns true if fm can shadow the provider that was there before.
+// A provider that is not included cannot shadow anything but it can
+// itself be shadowed by a later included provider, so it is only
+// recorded if no included provider is recorded for tc.
+func recordShadower(providedBy map[typeCode]*provider, fm *provider, tc typeCode, previous *provider, found bool) bool {
+	if fm.include {
+		providedBy[tc] = fm
+		return true
+	}
+	if !found || !previous.include {
+		providedBy[tc] = fm
+	}
+	return false
+}
+
+// shadow is one reason that a provider is not consulted
+type shadow struct {
+	by   *provider
+	what string
+}
+
+// shadows collects the shadowed providers so that each one is
+// reported once no matter how many providers shadow it
+type shadows struct {
+	order      []*provider
+	by         map[*provider][]shadow
+	overridden map[*provider]bool
+}
+
+func newShadows() *shadows {
+	return &shadows{
+		by:         make(map[*provider][]shadow),
+		overridden: make(map[*provider]bool),
+	}
+}
+
+// add records that fm shadows other for tc
+func (s *shadows) add(fm *provider, other *provider, tc typeCode, purpose string) {
+	if fm == other || fm.isSynthetic || fm.class == invokeFunc || fm.class == initFunc || fm.isDefaultFor(tc) || other.isDefaultFor(tc) {
+		return
+	}
+	if fm.overrides {
+		s.overridden[other] = true
+		return
+	}
+	what := purpose + tc.String()
+	for _, existing := range s.by[other] {
+		if existing.by == fm && existing.what == what {
+			return
+		}
+	}
+	if _, found := s.by[other]; !found {
+		s.order = append(s.order, other)
+	}
+	s.by[other] = append(s.by[other], shadow{by: fm, what: what})
+}
+
+// loose looks at the parameters of fm that were matched to a type that
+// implements an interface (through the rmap) and records that the
+// provider that was picked shadows the other providers whose types
+// implement the same interface.
+func (s *shadows) loose(fm *provider, param flowType, rmap map[typeCode]typeCode, providedBy map[typeCode]*provider) {
+	purpose := ""
+	if param == returnedParams {
+		purpose = "returned "
+	}
+	for _, tc := range fm.flows[param] {
+		rm, found := rmap[tc]
+		if !found || rm == tc || tc.Type().Kind() != reflect.Interface || !rm.Type().AssignableTo(tc.Type()) {
+			continue
+		}
+		picked, found := providedBy[rm]
+		if !found {
+			continue
+		}
+		others := make([]typeCode, 0, len(providedBy))
+		for other, otherFm := range providedBy {
+			if !otherFm.loose && !(param == returnedParams && fm.loose) {
+				// other could not have been matched
+				continue
+			}
+			if other != rm && other != tc && other.Type().AssignableTo(tc.Type()) {
+				others = append(others, other)
+			}
+		}
+		sort.Slice(others, func(i, j int) bool { return others[i].String() < others[j].String() })
+		for _, other := range others {
+			s.add(picked, providedBy[other], tc, purpose)
+		}
+	}
+}
+
+func (s *shadows) problems() []string {
+	problems := make([]string, 0, len(s.order))
+	for _, other := range s.order {
+		by := s.by[other]
+		if len(by) == 1 {
+			problems = append(problems, fmt.Sprintf("%s shadows %s for %s", by[0].by, other, by[0].what))
+			continue
+		}
+		reasons := make([]string, len(by))
+		for i, sh := range by {
+			reasons[i] = fmt.Sprintf("%s for %s", sh.by, sh.what)
+		}
+		problems = append(problems, fmt.Sprintf("%s is shadowed by %s", other, strings.Join(reasons, ", and by ")))
+	}
+	return problems
+}
+
+func checkStrict(funcs []*provider, options bindOptions) error {
+	if !options.strict && options.strictWarnings == nil {
+		return nil
+	}
+	problems := strictProblems(funcs)
+	if len(problems) == 0 {
+		return nil
+	}
+	options.debug.debugln("strict problems:")
+	for _, p := range problems {
+		options.debug.debugln("\t" + p)
+	}
+	if options.strict {
+		return fmt.Errorf("strict: %s", strings.Join(problems, "; "))
+	}
+	for _, p := range problems {
+		_, _ = fmt.Fprintln(options.strictWarnings, "nject strict:", p)
+	}
+	return nil
+}
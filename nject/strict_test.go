@@ -0,0 +1,183 @@
+package nject
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrict(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s2
+
+		clean := Sequence("clean",
+			func() s1 { return "a" },
+			func(s s1) s2 { return s2(s) },
+		)
+		require.NoError(t, clean.Bind(&invoke, nil, Strict()))
+		assert.Equal(t, s2("a"), invoke())
+
+		unused := Sequence("unused",
+			Provide("S3", func() s3 { return "" }),
+			func() s1 { return "a" },
+			func(s s1) s2 { return s2(s) },
+		)
+		require.NoError(t, unused.Bind(&invoke, nil))
+		err := unused.Bind(&invoke, nil, Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "S3")
+			assert.Contains(t, err.Error(), "is not included")
+		}
+
+		shadowed := Sequence("shadowed",
+			Provide("first", func() s1 { return "first" }),
+			Provide("second", func() s1 { return "second" }),
+			func(s s1) s2 { return s2(s) },
+		)
+		err = shadowed.Bind(&invoke, nil, Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "second")
+			assert.Contains(t, err.Error(), "shadows")
+		}
+
+		var warnings strings.Builder
+		require.NoError(t, shadowed.Bind(&invoke, nil, StrictWarnings(&warnings)))
+		assert.Equal(t, s2("second"), invoke())
+		assert.Contains(t, warnings.String(), "shadows")
+		assert.Contains(t, warnings.String(), "first")
+
+		overridden := Sequence("overridden",
+			Provide("first", func() s1 { return "first" }),
+			Provide("second", Overrides(func() s1 { return "second" })),
+			func(s s1) s2 { return s2(s) },
+		)
+		require.NoError(t, overridden.Bind(&invoke, nil, Strict()))
+		assert.Equal(t, s2("second"), invoke())
+
+		transformed := Sequence("transformed",
+			func() s1 { return "a" },
+			func(s s1) s1 { return s + "b" },
+			func(s s1) s2 { return s2(s) },
+		)
+		require.NoError(t, transformed.Bind(&invoke, nil, Strict()))
+		assert.Equal(t, s2("ab"), invoke())
+	})
+}
+
+func TestStrictDetailedError(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s2
+		err := Sequence("shadowed",
+			Provide("first", func() s1 { return "first" }),
+			Provide("second", func() s1 { return "second" }),
+			func(s s1) s2 { return s2(s) },
+		).Bind(&invoke, nil, Strict())
+		require.Error(t, err)
+		detailed := DetailedError(err)
+		assert.Contains(t, detailed, "strict problems:")
+		assert.Contains(t, detailed, "\tinjector: shadowed/second")
+	})
+}
+
+func TestStrictShadowedReturns(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s2
+		err := Sequence("returns",
+			Provide("wrapper", func(inner func()) s2 {
+				inner()
+				return "wrapper"
+			}),
+			Provide("final", func() s2 { return "final" }),
+		).Bind(&invoke, nil, Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "wrapper")
+			assert.Contains(t, err.Error(), "shadows")
+			assert.Contains(t, err.Error(), "for returned nject.s2")
+		}
+
+		require.NoError(t, Sequence("passed up",
+			func(inner func() s2) s2 { return inner() + "!" },
+			func() s2 { return "final" },
+		).Bind(&invoke, nil, Strict()))
+		assert.Equal(t, s2("final!"), invoke())
+	})
+}
+
+func TestStrictShadowedLoose(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() string
+		err := Sequence("loose",
+			Provide("A", Loose(func() ambiguousA { return 1 })),
+			Provide("B", Loose(func() ambiguousB { return 2 })),
+			func(s fmt.Stringer) string { return s.String() },
+		).Bind(&invoke, nil, Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "loose/B")
+			assert.Contains(t, err.Error(), "shadows")
+			assert.Contains(t, err.Error(), "loose/A")
+		}
+
+		err = Sequence("loose returned",
+			Loose(func(inner func() fmt.Stringer) string { return inner().String() }),
+			Provide("wrapA", ConsumptionOptional(func(inner func() ambiguousB) ambiguousA {
+				inner()
+				return 1
+			})),
+			Provide("finalB", func() ambiguousB { return 2 }),
+		).Bind(&invoke, nil, Strict())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "wrapA")
+			assert.Contains(t, err.Error(), "shadows")
+			assert.Contains(t, err.Error(), "finalB")
+			assert.Contains(t, err.Error(), "for returned fmt.Stringer")
+		}
+	})
+}
+
+func TestStrictShadowedOnce(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s3
+		var warnings strings.Builder
+		require.NoError(t, Sequence("twice",
+			Provide("first", func() (s1, s2) { return "1", "2" }),
+			Provide("second", func() s1 { return "1" }),
+			Provide("third", func() s2 { return "2" }),
+			func(a s1, b s2) s3 { return s3(a) + s3(b) },
+		).Bind(&invoke, nil, StrictWarnings(&warnings)))
+		assert.Equal(t, 1, strings.Count(warnings.String(), "twice/first"), warnings.String())
+		assert.Contains(t, warnings.String(), "twice/second")
+		assert.Contains(t, warnings.String(), "twice/third")
+	})
+}
+
+func TestStrictExcludedDoesNotShadow(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func() s2
+		var warnings strings.Builder
+		require.NoError(t, Sequence("excluded",
+			Provide("A", func() s1 { return "a" }),
+			Provide("B", func(s9) s1 { return "b" }),
+			func(s s1) s2 { return s2(s) },
+		).Bind(&invoke, nil, StrictWarnings(&warnings)))
+		assert.Equal(t, s2("a"), invoke())
+		assert.NotContains(t, warnings.String(), "shadow", warnings.String())
+		assert.Contains(t, warnings.String(), "excluded/B")
+		assert.Contains(t, warnings.String(), "is not included")
+
+		var returnInvoke func() s2
+		warnings.Reset()
+		require.NoError(t, Sequence("excluded returns",
+			Provide("B", func(inner func(), _ s9) s2 {
+				inner()
+				return "b"
+			}),
+			Provide("A", func() s2 { return "a" }),
+		).Bind(&returnInvoke, nil, StrictWarnings(&warnings)))
+		assert.Equal(t, s2("a"), returnInvoke())
+		assert.NotContains(t, warnings.String(), "shadow", warnings.String())
+		assert.Contains(t, warnings.String(), "excluded returns/B")
+	})
+}
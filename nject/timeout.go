@@ -0,0 +1,251 @@
+package nject
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TimeoutError is returned by providers annotated with Timeout
+// when they take too long.
+type TimeoutError struct {
+	Duration time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Duration)
+}
+
+// Unwrap makes errors.Is(err, context.DeadlineExceeded) true
+func (e TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// Timeout creates a provider that must finish within d.  fn must be an
+// injector, a fallible injector, or a wrapper.
+//
+// An injector becomes a fallible injector: if it does not finish in time,
+// the chain stops and TimeoutError is returned as the TerminalError.
+//
+// For a wrapper, the deadline covers the wrapper and the rest of the chain
+// below it.  The wrapper must return error.  If it does not finish in time,
+// the wrapper returns zero values and TimeoutError as its error.
+//
+// If fn takes a context.Context, it is given one with the deadline.  A
+// wrapper that takes a context.Context also passes it down the chain so
+// that providers below it see the deadline.  The context is cancelled when
+// fn returns so an injector cannot return a context.Context: the
+// providers below it would get a context that is already cancelled.
+//
+// When the deadline passes, fn keeps running in the background: its
+// results are discarded.  A wrapper and the chain below it run on their
+// own copy of the values in the chain so that what they do after the
+// deadline does not affect the caller, even if the caller calls the
+// chain again.  Providers should watch their context.Context so they
+// stop promptly.
+//
+// Timeout panics if fn is not a function, is a wrapper that does not
+// return error, or is an injector that returns context.Context.  Timeout
+// cannot be used on the final function in the chain: Bind returns an error.
+//
+// When used on an existing Provider, it creates an annotated copy of that provider.
+func Timeout(d time.Duration, fn interface{}) Provider {
+	return newThing(fn).modify(func(fm *provider) {
+		v := reflect.ValueOf(fm.fn)
+		if !v.IsValid() || v.Kind() != reflect.Func {
+			panic(fmt.Sprintf("Timeout requires a function, not %T", fm.fn))
+		}
+		in := typesIn(v.Type())
+		if len(in) > 0 && in[0].Kind() == reflect.Func && in[0].Name() == "" {
+			// the deadline for wrappers is enforced by generateWrappers
+			fm.fn = makeTimeoutWrapper(d, v)
+			fm.timeout = d
+			return
+		}
+		fm.fn = makeTimeoutInjector(d, v)
+		fm.timeoutInjector = true
+	})
+}
+
+func typeIndex(types []reflect.Type, t reflect.Type) int {
+	for i, typ := range types {
+		if typ == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// runWithDeadline calls v in the background and waits for it to
+// finish or for the deadline.  If v takes a context.Context, the
+// context is replaced by one with the deadline.  The context is
+// cancelled when runWithDeadline returns.  If v panics before the
+// deadline, the panic is passed on to the caller.
+func runWithDeadline(d time.Duration, v reflect.Value, inputs []reflect.Value, contextIndex int) ([]reflect.Value, bool) {
+	var ctx context.Context
+	if contextIndex >= 0 {
+		parent, _ := inputs[contextIndex].Interface().(context.Context)
+		if parent == nil {
+			parent = context.Background()
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, d)
+		defer cancel()
+		inputs[contextIndex] = reflect.ValueOf(&ctx).Elem()
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), d)
+		defer cancel()
+	}
+	type result struct {
+		out       []reflect.Value
+		panicked  bool
+		recovered interface{}
+	}
+	done := make(chan result, 1)
+	go func() {
+		r := result{panicked: true}
+		defer func() {
+			if r.panicked {
+				r.recovered = recover()
+			}
+			done <- r
+		}()
+		if v.Type().IsVariadic() {
+			r.out = v.CallSlice(inputs)
+		} else {
+			r.out = v.Call(inputs)
+		}
+		r.panicked = false
+	}()
+	select {
+	case r := <-done:
+		if r.panicked {
+			panic(r.recovered)
+		}
+		return r.out, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+func makeTimeoutInjector(d time.Duration, v reflect.Value) interface{} {
+	t := v.Type()
+	out := typesOut(t)
+	if typeIndex(out, contextType) != -1 {
+		panic(fmt.Sprintf("Timeout cannot be used with injectors that return context.Context like %s", t))
+	}
+	errorIndex := typeIndex(out, terminalErrorType)
+	timeoutOut := out
+	if errorIndex == -1 {
+		errorIndex = len(out)
+		timeoutOut = append(append(make([]reflect.Type, 0, len(out)+1), out...), terminalErrorType)
+	}
+	contextIndex := typeIndex(typesIn(t), contextType)
+	return reflect.MakeFunc(reflect.FuncOf(typesIn(t), timeoutOut, t.IsVariadic()), func(inputs []reflect.Value) []reflect.Value {
+		results, ok := runWithDeadline(d, v, inputs, contextIndex)
+		if !ok {
+			results = make([]reflect.Value, len(timeoutOut))
+			for i, typ := range timeoutOut {
+				results[i] = reflect.Zero(typ)
+			}
+			err := reflect.New(terminalErrorType).Elem()
+			err.Set(reflect.ValueOf(TimeoutError{Duration: d}))
+			results[errorIndex] = err
+			return results
+		}
+		if len(results) < len(timeoutOut) {
+			results = append(results, reflect.Zero(terminalErrorType))
+		}
+		return results
+	}).Interface()
+}
+
+func makeTimeoutWrapper(d time.Duration, v reflect.Value) interface{} {
+	t := v.Type()
+	out := typesOut(t)
+	errorIndex := typeIndex(out, errorType)
+	if errorIndex == -1 {
+		panic(fmt.Sprintf("Timeout requires wrapper %s to return error", t))
+	}
+	in := typesIn(t)
+	contextIndex := typeIndex(in, contextType)
+	innerType := in[0]
+	passContext := contextIndex >= 0 && typeIndex(typesIn(innerType), contextType) == -1 && !innerType.IsVariadic()
+	if passContext {
+		in[0] = reflect.FuncOf(append(typesIn(innerType), contextType), typesOut(innerType), false)
+	}
+	if contextIndex == -1 {
+		return v.Interface()
+	}
+	// The wrapper is given a context with the deadline.  The deadline
+	// itself is enforced by timeoutWrapper.
+	return reflect.MakeFunc(reflect.FuncOf(in, out, t.IsVariadic()), func(inputs []reflect.Value) []reflect.Value {
+		inputs = append([]reflect.Value(nil), inputs...)
+		parent, _ := inputs[contextIndex].Interface().(context.Context)
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+		inputs[contextIndex] = reflect.ValueOf(&ctx).Elem()
+		if passContext {
+			timeoutInner := inputs[0]
+			inputs[0] = reflect.MakeFunc(innerType, func(args []reflect.Value) []reflect.Value {
+				return timeoutInner.Call(append(append(make([]reflect.Value, 0, len(args)+1), args...), inputs[contextIndex]))
+			})
+		}
+		if t.IsVariadic() {
+			return v.CallSlice(inputs)
+		}
+		return v.Call(inputs)
+	}).Interface()
+}
+
+// timeoutWrapper runs a wrapper annotated with Timeout, and the chain below
+// it, on a private copy of downV.  If the deadline passes first, whatever
+// it does later is discarded and the wrapper's results are zero values
+// and TimeoutError.
+func timeoutWrapper(
+	d time.Duration,
+	wrap func(valueCollection, func(valueCollection) valueCollection) valueCollection,
+	zero func() valueCollection,
+	timedOut func(upV valueCollection),
+) func(valueCollection, func(valueCollection) valueCollection) valueCollection {
+	return func(downV valueCollection, next func(valueCollection) valueCollection) valueCollection {
+		private := downV.Copy()
+		type result struct {
+			upV       valueCollection
+			panicked  bool
+			recovered interface{}
+		}
+		done := make(chan result, 1)
+		go func() {
+			r := result{panicked: true}
+			defer func() {
+				if r.panicked {
+					r.recovered = recover()
+				}
+				done <- r
+			}()
+			r.upV = wrap(private, next)
+			r.panicked = false
+		}()
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case r := <-done:
+			if r.panicked {
+				panic(r.recovered)
+			}
+			return r.upV
+		case <-timer.C:
+			upV := zero()
+			timedOut(upV)
+			return upV
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package nject
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutInjector(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var hadDeadline bool
+		var invoke func(context.Context, s1) (s2, error)
+		require.NoError(t, Sequence("timeout",
+			Timeout(20*time.Millisecond, func(ctx context.Context, s s1) s2 {
+				_, hadDeadline = ctx.Deadline()
+				if s == "hang" {
+					<-ctx.Done()
+				}
+				return s2(s)
+			}),
+			func(s s2) (s2, error) { return s, nil },
+		).Bind(&invoke, nil))
+
+		got, err := invoke(context.Background(), "fast")
+		require.NoError(t, err)
+		assert.Equal(t, s2("fast"), got)
+		assert.True(t, hadDeadline)
+
+		_, err = invoke(context.Background(), "hang")
+		require.Error(t, err)
+		var timeoutError TimeoutError
+		require.True(t, errors.As(err, &timeoutError), "%T", err)
+		assert.Equal(t, 20*time.Millisecond, timeoutError.Duration)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestTimeoutFallibleInjectorWithoutContext(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		var invoke func(s1) (s2, error)
+		require.NoError(t, Sequence("timeout",
+			Timeout(10*time.Millisecond, func(s s1) (s2, TerminalError) {
+				if s == "hang" {
+					<-release
+				}
+				if s == "fail" {
+					return "", errors.New("failed")
+				}
+				return s2(s), nil
+			}),
+			func(s s2) (s2, error) { return s, nil },
+		).Bind(&invoke, nil))
+
+		got, err := invoke("fast")
+		require.NoError(t, err)
+		assert.Equal(t, s2("fast"), got)
+		_, err = invoke("fail")
+		assert.EqualError(t, err, "failed")
+		_, err = invoke("hang")
+		assert.EqualError(t, err, "timed out after 10ms")
+	})
+}
+
+func TestTimeoutWrapper(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func(context.Context, s1) (s2, error)
+		require.NoError(t, Sequence("timeout",
+			Timeout(20*time.Millisecond, func(inner func(s1) (s2, error), ctx context.Context, s s1) (s2, error) {
+				return inner(s)
+			}),
+			func(ctx context.Context, s s1) (s2, error) {
+				if _, ok := ctx.Deadline(); !ok {
+					return "", errors.New("no deadline below the wrapper")
+				}
+				if s == "hang" {
+					<-ctx.Done()
+				}
+				return s2(s), nil
+			},
+		).Bind(&invoke, nil))
+
+		got, err := invoke(context.Background(), "fast")
+		require.NoError(t, err)
+		assert.Equal(t, s2("fast"), got)
+
+		got, err = invoke(context.Background(), "hang")
+		assert.EqualError(t, err, "timed out after 20ms")
+		assert.Equal(t, s2(""), got)
+	})
+}
+
+func TestTimeoutMisuse(t *testing.T) {
+	assert.Panics(t, func() { Timeout(time.Second, "not a function") })
+	assert.Panics(t, func() { Timeout(time.Second, func(inner func() s1) s1 { return inner() }) })
+	assert.Panics(t, func() {
+		Timeout(time.Second, func(ctx context.Context) context.Context { return ctx })
+	}, "the context is cancelled when the injector returns")
+
+	var invoke func(s1) error
+	err := Sequence("final", Timeout(time.Second, func(s1) {})).Bind(&invoke, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is marked Timeout")
+	}
+}
+
+func TestTimeoutInjectorPanics(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func(s1) (s2, error)
+		require.NoError(t, Sequence("panics",
+			Timeout(time.Second, func(s s1) s2 {
+				if s == "panic" {
+					panic("injector panic")
+				}
+				return s2(s)
+			}),
+			func(s s2) (s2, error) { return s, nil },
+		).Bind(&invoke, nil))
+
+		got, err := invoke("fine")
+		require.NoError(t, err)
+		assert.Equal(t, s2("fine"), got)
+		assert.PanicsWithValue(t, "injector panic", func() { _, _ = invoke("panic") })
+	})
+}
+
+func TestTimeoutWrapperCalledAgain(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var attempts int32
+		release := make(chan struct{})
+		lateDone := make(chan s2, 1)
+		var invoke func() (s2, error)
+		require.NoError(t, Sequence("again",
+			func(inner func() (s2, error)) (s2, error) {
+				if _, err := inner(); err == nil {
+					return "", errors.New("expected a timeout")
+				}
+				close(release)
+				// the first attempt runs the chain below it again while
+				// this attempt is running: they must not share values
+				got, err := inner()
+				late := <-lateDone
+				return got + " " + late, err
+			},
+			Timeout(10*time.Millisecond, func(inner func(s1) (s2, error)) (s2, error) {
+				if atomic.AddInt32(&attempts, 1) == 1 {
+					<-release
+					got, err := inner("late")
+					lateDone <- got
+					return got, err
+				}
+				return inner("second")
+			}),
+			func(s s1) s3 { return s3(s) + "-3" },
+			func(s s1, x s3) (s2, error) { return s2(x), nil },
+		).Bind(&invoke, nil))
+
+		got, err := invoke()
+		require.NoError(t, err)
+		assert.Equal(t, s2("second-3 late-3"), got)
+	})
+}
@@ -0,0 +1,134 @@
+package nject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// InvokeTrace records what happens during a single call to an invoke
+// function.  To trace an invocation, include *InvokeTrace as one of
+// the inputs of the invoke function and pass a non-nil value:
+//
+//	var handle func(*nject.InvokeTrace, *http.Request)
+//	...
+//	trace := &nject.InvokeTrace{}
+//	handle(trace, r)
+//	log.Print(trace)
+//
+// Passing nil turns off tracing for that call.  Only the providers in the
+// RUN chain are traced: the STATIC chain does not run per invocation.
+type InvokeTrace struct {
+	// Redact, if set, is used to format every value that is recorded.
+	// Use it to hide secrets.  Without it, values are formatted
+	// with fmt's %v.
+	Redact func(t reflect.Type, value interface{}) string
+
+	// Events is the record of the invocation in the order that the
+	// providers ran.
+	Events []TraceEvent
+
+	mu sync.Mutex
+}
+
+// TraceEvent is one step in an InvokeTrace
+type TraceEvent struct {
+	// Provider is the name of the provider, the same as ProviderInfo.Path
+	Provider string
+	// Class is the kind of provider, eg "injector" or "wrapper-func"
+	Class string
+	// Phase is "provided" for values passed down the chain (outputs of
+	// injectors and the values a wrapper passes to inner()), "returned"
+	// for values passed back up the chain, and "short-circuit" when a
+	// fallible injector returns an error that stops the chain.
+	Phase string
+	// Values are the values that were provided or returned
+	Values []TraceValue
+	// Error is set when Phase is "short-circuit"
+	Error string
+}
+
+// TraceValue is a formatted value in a TraceEvent
+type TraceValue struct {
+	Type  string
+	Value string
+}
+
+var invokeTraceTypeCode = getTypeCode(reflect.TypeOf(&InvokeTrace{}))
+
+// String formats the trace with one event per line
+func (t *InvokeTrace) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	for _, e := range t.Events {
+		fmt.Fprintf(&b, "%s %s", e.Provider, e.Phase)
+		if e.Error != "" {
+			fmt.Fprintf(&b, " error: %s", e.Error)
+		}
+		for _, v := range e.Values {
+			fmt.Fprintf(&b, " %s=%s", v.Type, v.Value)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (t *InvokeTrace) format(typ reflect.Type, v reflect.Value) string {
+	var value interface{}
+	if v.IsValid() && v.CanInterface() {
+		value = v.Interface()
+	}
+	if t.Redact != nil {
+		return t.Redact(typ, value)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (t *InvokeTrace) record(fm *provider, phase string, flow []typeCode, values []reflect.Value, err error) {
+	e := TraceEvent{
+		Provider: fm.fullName(),
+		Class:    string(fm.class),
+		Phase:    phase,
+	}
+	for i, tc := range flow {
+		if tc == noTypeCode || i >= len(values) {
+			continue
+		}
+		e.Values = append(e.Values, TraceValue{
+			Type:  tc.Type().String(),
+			Value: t.format(tc.Type(), values[i]),
+		})
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Events = append(t.Events, e)
+}
+
+// tracer finds the InvokeTrace, if any, in a value collection
+type tracer int
+
+func makeTracer(downVmap map[typeCode]int) tracer {
+	if i, found := downVmap[invokeTraceTypeCode]; found && i >= 0 {
+		return tracer(i)
+	}
+	return -1
+}
+
+// enabled is false when the invoke function does not take an
+// *InvokeTrace so there is never anything to record
+func (t tracer) enabled() bool {
+	return t >= 0
+}
+
+func (t tracer) from(v valueCollection) *InvokeTrace {
+	if t < 0 || !v[t].IsValid() {
+		return nil
+	}
+	trace, _ := v[t].Interface().(*InvokeTrace)
+	return trace
+}
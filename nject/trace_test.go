@@ -0,0 +1,61 @@
+package nject
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeTrace(t *testing.T) {
+	wrapTest(t, func(t *testing.T) {
+		var invoke func(*InvokeTrace, s1) (s3, error)
+		require.NoError(t, Sequence("trace",
+			Provide("wrap", func(inner func(s2) s3, a s1) (s3, error) {
+				return inner(s2(a) + "-w"), nil
+			}),
+			Provide("check", func(b s2) (s4, TerminalError) {
+				if b == "bad-w" {
+					return "", errors.New("bad input")
+				}
+				return s4("checked-" + b), nil
+			}),
+			Provide("final", func(d s4) s3 { return s3(d) }),
+		).Bind(&invoke, nil))
+
+		trace := &InvokeTrace{}
+		got, err := invoke(trace, "good")
+		require.NoError(t, err)
+		assert.Equal(t, s3("checked-good-w"), got)
+		assert.Equal(t, []TraceEvent{
+			{Provider: "trace/wrap", Class: "wrapper-func", Phase: "provided", Values: []TraceValue{{Type: "nject.s2", Value: "good-w"}}},
+			{Provider: "trace/check", Class: "fallible-injector", Phase: "provided", Values: []TraceValue{{Type: "nject.s4", Value: "checked-good-w"}}},
+			{Provider: "trace/final", Class: "final-func", Phase: "returned", Values: []TraceValue{{Type: "nject.s3", Value: "checked-good-w"}}},
+			{Provider: "trace/wrap", Class: "wrapper-func", Phase: "returned", Values: []TraceValue{{Type: "nject.s3", Value: "checked-good-w"}, {Type: "error", Value: "<nil>"}}},
+		}, trace.Events)
+		t.Log(trace)
+
+		trace = &InvokeTrace{
+			Redact: func(t reflect.Type, v interface{}) string {
+				if t == reflect.TypeOf(s2("")) {
+					return "REDACTED"
+				}
+				return "x"
+			},
+		}
+		_, _ = invoke(trace, "bad")
+		require.Len(t, trace.Events, 3)
+		assert.Equal(t, []TraceValue{{Type: "nject.s2", Value: "REDACTED"}}, trace.Events[0].Values)
+		assert.Equal(t, "short-circuit", trace.Events[1].Phase)
+		assert.Equal(t, "bad input", trace.Events[1].Error)
+		assert.Equal(t, "trace/wrap", trace.Events[2].Provider)
+		assert.Contains(t, trace.String(), "trace/check short-circuit error: bad input")
+
+		// tracing is off when the trace is nil
+		got, err = invoke(nil, "good")
+		require.NoError(t, err)
+		assert.Equal(t, s3("checked-good-w"), got)
+	})
+}
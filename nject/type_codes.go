@@ -3,6 +3,7 @@ package nject
 // TODO: switch from typeCode to reflect.Type
 
 import (
+	"fmt"
 	"reflect"
 	"sync"
 )
@@ -49,7 +50,11 @@ func (tc typeCode) Type() reflect.Type {
 	return reverseMap[tc]
 }
 
-// Type returns the reflect.Type for this typeCode
+// String returns the name of the type.  Types that are private
+// to a Module are marked as such.
 func (tc typeCode) String() string {
+	if m, _ := tc.scope(); m != nil {
+		return fmt.Sprintf("%s (private to %s)", tc.Type(), m.name)
+	}
 	return tc.Type().String()
 }
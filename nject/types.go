@@ -50,6 +50,57 @@ type Debugging struct {
 	// a provider chain as a unit test.  This output is nearly runnable
 	// code.  It may need a bit of customization to fully capture a situation.
 	Reproduce string
+
+	// Providers is a structured version of IncludeExclude: one entry
+	// for each provider supplied to create the chain, in chain order.
+	Providers []ProviderInfo
+
+	reproduceFile func(pkgName string) string
+}
+
+// ReproduceFile is like Reproduce except that it is a complete, gofmt'ed,
+// _test.go file in package pkgName.  Unlike Reproduce, the generated test
+// references the nject package by its import path and asserts that
+// the providers that were included in the chain are called and that the
+// providers that were excluded are not called.
+func (d *Debugging) ReproduceFile(pkgName string) string {
+	if d.reproduceFile == nil {
+		return ""
+	}
+	return d.reproduceFile(pkgName)
+}
+
+// ProviderInfo describes one provider in a chain and whether or not it was
+// included.  It is a machine-readable companion to Debugging.IncludeExclude.
+type ProviderInfo struct {
+	// Name is the same as the name used in Debugging.NamesIncluded
+	Name string `json:"name"`
+	// Path is the name prefixed by the names of the collections
+	// that contain the provider, eg "service/auth/loadUser"
+	Path string `json:"path,omitempty"`
+	// Location is the source file and line of the provider function,
+	// eg "service/auth.go:42".  It is empty for literal values.
+	Location string `json:"location,omitempty"`
+	// Index is the position of the provider in the collection where it
+	// was named or -1 if the provider was explicitly named.
+	Index int `json:"index"`
+	// Class is the kind of provider, eg "injector" or "wrapper-func"
+	Class string `json:"class"`
+	// Group is the set the provider is in: "literal", "static",
+	// "run", "final", or "invoke"
+	Group string `json:"group"`
+	// Included is true if the provider is part of the final chain
+	Included bool `json:"included"`
+	// Reason explains why the provider was included or excluded
+	Reason string `json:"reason"`
+	// Flows lists the types, by flow direction, that the provider
+	// consumes and produces.  The keys are "inputs", "outputs",
+	// "returns", "returned", and "bypass".
+	Flows map[string][]string `json:"flows,omitempty"`
+	// Conversions lists the inputs and returned values of the provider
+	// that are converted from another type because of ConvertibleTo or
+	// AutoConvert, eg "main.UserID -> main.AccountOwnerID"
+	Conversions []string `json:"conversions,omitempty"`
 }
 
 type classType string
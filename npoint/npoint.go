@@ -5,7 +5,6 @@ package npoint
 // TODO: inject route as a mux.Route type.
 // TODO: Duplicate service
 // TODO: Duplicate endpoint
-// TODO: When making copies of valueCollections, do deep copies when they implement a DeepCopy method.
 // TODO: Re-use slots in the value collection when values do not overlap in time
 // TODO: order the value collection so that middleware can make only partial copies
 // TODO: new annotator: skip copying the value collection